@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// apiError is an error the API can return. Every apiError is listed by the
+// /errors endpoint.
+type apiError struct {
+	Code        string `json:"code"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+// errorCatalog lists every apiError, in the order they are declared.
+var errorCatalog []*apiError
+
+// newAPIError declares an apiError and adds it to the catalog.
+func newAPIError(code string, status int, description string) *apiError {
+	e := &apiError{code, status, description}
+	errorCatalog = append(errorCatalog, e)
+	return e
+}
+
+var (
+	errMissingCity      = newAPIError("missing_city", http.StatusBadRequest, "No city was given. Only /weather falls back to -default-city, when one is set.")
+	errInvalidParameter = newAPIError("invalid_parameter", http.StatusBadRequest, "A query parameter is missing, malformed or out of range.")
+	errGeolocation      = newAPIError("geolocation_failed", http.StatusServiceUnavailable, "The client's location could not be determined from its IP address.")
+	errUpstream         = newAPIError("upstream_failed", http.StatusInternalServerError, "The upstream weather or geocoding APIs failed to answer.")
+	errMethodNotAllowed = newAPIError("method_not_allowed", http.StatusMethodNotAllowed, "The endpoint does not accept this HTTP method.")
+	errForbidden        = newAPIError("forbidden", http.StatusForbidden, "The endpoint is only available from localhost.")
+)
+
+// writeError writes e as a JSON error response with a message describing
+// this occurrence of it.
+func writeError(writer http.ResponseWriter, e *apiError, message string) {
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	writer.WriteHeader(e.Status)
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"code":  e.Code,
+		"error": message,
+	})
+}
+
+// errorsHandler writes the error catalog as JSON.
+func errorsHandler(writer http.ResponseWriter, req *http.Request) {
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"errors": errorCatalog,
+	})
+}
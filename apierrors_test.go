@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrorCatalog(t *testing.T) {
+	declared := []*apiError{errMissingCity, errInvalidParameter, errGeolocation, errUpstream, errMethodNotAllowed, errForbidden}
+
+	rec := httptest.NewRecorder()
+	errorsHandler(rec, httptest.NewRequest(http.MethodGet, "/errors", nil))
+	var body struct {
+		Errors []apiError `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	listed := map[string]apiError{}
+	for _, e := range body.Errors {
+		if _, ok := listed[e.Code]; ok {
+			t.Errorf("code %s is listed twice", e.Code)
+		}
+		listed[e.Code] = e
+	}
+	for _, e := range declared {
+		if got, ok := listed[e.Code]; !ok || got != *e {
+			t.Errorf("%s is listed as %+v, want %+v", e.Code, got, *e)
+		}
+	}
+	if len(body.Errors) != len(declared) {
+		t.Errorf("/errors lists %d errors, want %d", len(body.Errors), len(declared))
+	}
+}
+
+// TestWriteErrorUsesCatalog checks that every writeError call in the source
+// passes an error declared with newAPIError, so none can be missing from
+// /errors.
+func TestWriteErrorUsesCatalog(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	declared := map[string]bool{}
+	var used []*ast.Ident
+	for _, name := range files {
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.ValueSpec:
+				for i, v := range n.Values {
+					if call, ok := v.(*ast.CallExpr); ok && isCall(call, "newAPIError") {
+						declared[n.Names[i].Name] = true
+					}
+				}
+			case *ast.CallExpr:
+				if isCall(n, "writeError") && len(n.Args) == 3 {
+					if id, ok := n.Args[1].(*ast.Ident); ok {
+						used = append(used, id)
+					} else {
+						t.Errorf("%s: writeError is passed %T, want a declared error", fset.Position(n.Pos()), n.Args[1])
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	if len(used) == 0 {
+		t.Fatal("found no writeError calls")
+	}
+	for _, id := range used {
+		if !declared[id.Name] {
+			t.Errorf("%s: %s is not declared with newAPIError", fset.Position(id.Pos()), id.Name)
+		}
+	}
+}
+
+func isCall(call *ast.CallExpr, name string) bool {
+	id, ok := call.Fun.(*ast.Ident)
+	return ok && id.Name == name
+}
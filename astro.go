@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Approximate lengths of the golden hour (sun low above the horizon) and
+// blue hour (sun just below the horizon) at either end of the day.
+const (
+	goldenHour = time.Hour
+	blueHour   = 20 * time.Minute
+)
+
+// interval is a span of time, encoded as RFC 3339 timestamps.
+type interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// daylight returns today's sunrise and sunset at l, in l's local time.
+func (o openMeteo) daylight(ctx context.Context, l location) (time.Time, time.Time, error) {
+	resp, err := fetch(ctx, fmt.Sprintf(
+		"%s/v1/forecast?latitude=%f&longitude=%f&daily=sunrise,sunset&timezone=auto&forecast_days=1",
+		openMeteoForecastURL, l.Latitude, l.Longitude))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	defer resp.Body.Close()
+
+	var d struct {
+		Offset       int    `json:"utc_offset_seconds"`
+		Abbreviation string `json:"timezone_abbreviation"`
+		Daily        struct {
+			Sunrise []string `json:"sunrise"`
+			Sunset  []string `json:"sunset"`
+		} `json:"daily"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if len(d.Daily.Sunrise) == 0 || len(d.Daily.Sunset) == 0 {
+		return time.Time{}, time.Time{}, errors.New("Open-Meteo returned no sunrise or sunset")
+	}
+
+	// Open-Meteo reports local times without an offset.
+	zone := time.FixedZone(d.Abbreviation, d.Offset)
+	sunrise, err := time.ParseInLocation("2006-01-02T15:04", d.Daily.Sunrise[0], zone)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	sunset, err := time.ParseInLocation("2006-01-02T15:04", d.Daily.Sunset[0], zone)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	logf(ctx, "Open-Meteo responded with sunrise %s and sunset %s for %.4f,%.4f\n",
+		sunrise.Format("15:04"), sunset.Format("15:04"), l.Latitude, l.Longitude)
+
+	return sunrise, sunset, nil
+}
+
+// goldenHours returns the morning and evening golden hours: the first and
+// last hour of sunlight.
+func goldenHours(sunrise, sunset time.Time) []interval {
+	return []interval{
+		{sunrise, sunrise.Add(goldenHour)},
+		{sunset.Add(-goldenHour), sunset},
+	}
+}
+
+// blueHours returns the morning and evening blue hours: the twilight just
+// before sunrise and just after sunset.
+func blueHours(sunrise, sunset time.Time) []interval {
+	return []interval{
+		{sunrise.Add(-blueHour), sunrise},
+		{sunset, sunset.Add(blueHour)},
+	}
+}
+
+// astro is the http handler for /astro. It returns today's sunrise, sunset,
+// golden hours and blue hours for the given city in its local time.
+func astro(writer http.ResponseWriter, req *http.Request) {
+	begin := time.Now()
+
+	city := req.URL.Query().Get("city")
+	if city == "" {
+		writeError(writer, errMissingCity, "a city must be given")
+		return
+	}
+
+	l, err := places.geocode(req.Context(), city)
+	if err != nil {
+		writeError(writer, errUpstream, err.Error())
+		return
+	}
+
+	sunrise, sunset, err := om.daylight(req.Context(), l)
+	if err != nil {
+		writeError(writer, errUpstream, err.Error())
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"city":         city,
+		"sunrise":      sunrise,
+		"sunset":       sunset,
+		"golden_hours": goldenHours(sunrise, sunset),
+		"blue_hours":   blueHours(sunrise, sunset),
+		"attribution":  []string{openMeteoAttribution},
+		"took":         formatTook(req, time.Since(begin)),
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGoldenAndBlueHours(t *testing.T) {
+	zone := time.FixedZone("BST", 3600)
+	sunrise := time.Date(2026, 10, 14, 7, 22, 0, 0, zone)
+	sunset := time.Date(2026, 10, 14, 18, 13, 0, 0, zone)
+
+	tests := []struct {
+		name string
+		got  []interval
+		want []interval
+	}{
+		{"golden", goldenHours(sunrise, sunset), []interval{
+			{sunrise, time.Date(2026, 10, 14, 8, 22, 0, 0, zone)},
+			{time.Date(2026, 10, 14, 17, 13, 0, 0, zone), sunset},
+		}},
+		{"blue", blueHours(sunrise, sunset), []interval{
+			{time.Date(2026, 10, 14, 7, 2, 0, 0, zone), sunrise},
+			{sunset, time.Date(2026, 10, 14, 18, 33, 0, 0, zone)},
+		}},
+	}
+
+	for _, tt := range tests {
+		if len(tt.got) != len(tt.want) {
+			t.Fatalf("%s hours: got %d intervals, want %d", tt.name, len(tt.got), len(tt.want))
+		}
+		for i := range tt.want {
+			if !tt.got[i].Start.Equal(tt.want[i].Start) || !tt.got[i].End.Equal(tt.want[i].End) {
+				t.Errorf("%s hour %d = %v to %v, want %v to %v", tt.name, i,
+					tt.got[i].Start, tt.got[i].End, tt.want[i].Start, tt.want[i].End)
+			}
+		}
+	}
+}
+
+func TestDaylightLocalTime(t *testing.T) {
+	useForecastAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"utc_offset_seconds": 3600, "timezone_abbreviation": "BST",
+			"daily": {"sunrise": ["2026-10-14T07:22"], "sunset": ["2026-10-14T18:13"]}}`)
+	})
+
+	sunrise, sunset, err := om.daylight(context.Background(), location{Latitude: 51.5, Longitude: -0.12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 10, 14, 6, 22, 0, 0, time.UTC); !sunrise.Equal(want) {
+		t.Errorf("sunrise = %v, want %v", sunrise, want)
+	}
+	if want := time.Date(2026, 10, 14, 17, 13, 0, 0, time.UTC); !sunset.Equal(want) {
+		t.Errorf("sunset = %v, want %v", sunset, want)
+	}
+	if name, offset := sunrise.Zone(); name != "BST" || offset != 3600 {
+		t.Errorf("sunrise is in %s %+d, want the city's local BST +3600", name, offset)
+	}
+}
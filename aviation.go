@@ -0,0 +1,52 @@
+package main
+
+import "math"
+
+// metresPerMile is the length of a statute mile.
+const metresPerMile = 1609.344
+
+// Flight categories, from best to worst conditions.
+const (
+	flightVFR  = "VFR"  // visual flight rules
+	flightMVFR = "MVFR" // marginal visual flight rules
+	flightIFR  = "IFR"  // instrument flight rules
+	flightLIFR = "LIFR" // low instrument flight rules
+)
+
+// flightCategory returns the FAA flight category for the visibility in
+// metres and ceiling (the lowest broken or overcast cloud layer) in feet.
+// Whichever of the two is worse decides:
+//
+//	LIFR  ceiling below 500 ft or visibility below 1 mile
+//	IFR   ceiling 500 to below 1,000 ft or visibility 1 to below 3 miles
+//	MVFR  ceiling 1,000 to 3,000 ft or visibility 3 to 5 miles
+//	VFR   ceiling above 3,000 ft and visibility above 5 miles
+func flightCategory(visibilityMeters, ceilingFeet float64) string {
+	miles := visibilityMeters / metresPerMile
+
+	switch {
+	case ceilingFeet < 500 || miles < 1:
+		return flightLIFR
+	case ceilingFeet < 1000 || miles < 3:
+		return flightIFR
+	case ceilingFeet <= 3000 || miles <= 5:
+		return flightMVFR
+	}
+	return flightVFR
+}
+
+// maxScatteredCover is the most cloud cover, in percent, that is still no
+// more than scattered (4 oktas), so that no layer forms a ceiling.
+const maxScatteredCover = 50
+
+// ceilingFromCover returns the ceiling implied by the cloud cover in percent:
+// unlimited when the sky is no more than scattered, and unknown (nil)
+// otherwise, since the height of a broken or overcast layer cannot be told
+// from its cover.
+func ceilingFromCover(cover *float64) *float64 {
+	if cover == nil || *cover > maxScatteredCover {
+		return nil
+	}
+	unlimited := math.Inf(1)
+	return &unlimited
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestFlightCategory(t *testing.T) {
+	mile := metresPerMile
+	tests := []struct {
+		visibilityMeters, ceilingFeet float64
+		want                          string
+	}{
+		{10 * mile, math.Inf(1), flightVFR},
+		{5.01 * mile, 3001, flightVFR},
+		{5 * mile, 3001, flightMVFR},
+		{10 * mile, 3000, flightMVFR},
+		{3 * mile, 1000, flightMVFR},
+		{2.99 * mile, 5000, flightIFR},
+		{10 * mile, 999, flightIFR},
+		{1 * mile, 500, flightIFR},
+		{0.99 * mile, 5000, flightLIFR},
+		{10 * mile, 499, flightLIFR},
+		{0, 0, flightLIFR},
+	}
+
+	for _, tt := range tests {
+		if got := flightCategory(tt.visibilityMeters, tt.ceilingFeet); got != tt.want {
+			t.Errorf("flightCategory(%.2f mi, %v ft) = %s, want %s", tt.visibilityMeters/mile, tt.ceilingFeet, got, tt.want)
+		}
+	}
+}
+
+func TestCeilingFromCover(t *testing.T) {
+	tests := []struct {
+		cover       *float64
+		wantCeiling bool
+	}{
+		{ptr(0), true},
+		{ptr(maxScatteredCover), true},
+		{ptr(maxScatteredCover + 1), false},
+		{ptr(100), false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		c := ceilingFromCover(tt.cover)
+		if (c != nil) != tt.wantCeiling || c != nil && !math.IsInf(*c, 1) {
+			t.Errorf("ceilingFromCover(%v) = %v, want an unlimited ceiling %v", tt.cover, c, tt.wantCeiling)
+		}
+	}
+}
+
+func TestWeatherFlightCategory(t *testing.T) {
+	tests := []struct {
+		name    string
+		obs     []observation
+		wantCat interface{}
+	}{
+		{"clear", []observation{{visibility: ptr(16000), ceiling: ptr(math.Inf(1))}}, flightVFR},
+		// The lowest ceiling decides, not the average.
+		{"one low ceiling", []observation{
+			{visibility: ptr(16000), ceiling: ptr(math.Inf(1))},
+			{visibility: ptr(16000), ceiling: ptr(800)},
+		}, flightIFR},
+		{"no ceiling reported", []observation{{visibility: ptr(16000)}}, nil},
+		{"no visibility reported", []observation{{ceiling: ptr(800)}}, nil},
+	}
+
+	for _, tt := range tests {
+		var group multiWeatherProvider
+		for i, o := range tt.obs {
+			o.kelvin = 290
+			group = append(group, &fakeProvider{id: string(rune('a' + i)), obs: o})
+		}
+		useGroups(t, group)
+
+		rec, body := getWeather(t, "/weather/Lisbon", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", tt.name, rec.Code, rec.Body)
+		}
+		if got := body["flight_category"]; got != tt.wantCat {
+			t.Errorf("%s: flight_category = %v, want %v", tt.name, got, tt.wantCat)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// cacheEntry is a provider reading held by readingCache.
+type cacheEntry struct {
+	obs     observation
+	expires time.Time
+}
+
+// readingCache holds the most recent reading from each provider for each
+// city. A zero ttl disables caching.
+type readingCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	jitter  float64
+	entries map[string]cacheEntry
+}
+
+var cache = &readingCache{entries: map[string]cacheEntry{}}
+
+func cacheKey(provider, city string) string {
+	return provider + "|" + city
+}
+
+// get returns the cached reading for the provider and city, if it has not
+// expired.
+func (c *readingCache) get(provider, city string) (observation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cacheKey(provider, city)
+	e, ok := c.entries[key]
+	if !ok {
+		return observation{}, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return observation{}, false
+	}
+	return e.obs, true
+}
+
+// sweep removes the entries that have expired by now, so keys that are not
+// asked for again, such as one-off cities or /tile coordinates, do not stay
+// in memory.
+func (c *readingCache) sweep(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// sweepEvery sweeps the cache every interval, for as long as the server
+// runs.
+func (c *readingCache) sweepEvery(interval time.Duration) {
+	for now := range time.Tick(interval) {
+		c.sweep(now)
+	}
+}
+
+// set stores a reading for the provider and city.
+func (c *readingCache) set(provider, city string, o observation) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[cacheKey(provider, city)] = cacheEntry{
+		obs:     o,
+		expires: now.Add(c.jitteredTTL()),
+	}
+}
+
+// jitteredTTL returns the ttl randomly adjusted by up to ±jitter (a fraction
+// of the ttl), so that entries stored together do not all expire together.
+func (c *readingCache) jitteredTTL() time.Duration {
+	if c.jitter <= 0 {
+		return c.ttl
+	}
+	offset := (rand.Float64()*2 - 1) * c.jitter * float64(c.ttl)
+	return c.ttl + time.Duration(offset)
+}
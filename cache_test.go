@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitteredTTL(t *testing.T) {
+	tests := []struct {
+		ttl    time.Duration
+		jitter float64
+	}{
+		{time.Minute, 0},
+		{time.Minute, 0.1},
+		{10 * time.Minute, 0.5},
+		{time.Second, 0.99},
+	}
+
+	for _, tt := range tests {
+		c := &readingCache{ttl: tt.ttl, jitter: tt.jitter}
+		low := tt.ttl - time.Duration(tt.jitter*float64(tt.ttl))
+		high := tt.ttl + time.Duration(tt.jitter*float64(tt.ttl))
+
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 1000; i++ {
+			d := c.jitteredTTL()
+			if d < low || d > high {
+				t.Fatalf("ttl %s jitter %v: got %s, want within [%s, %s]", tt.ttl, tt.jitter, d, low, high)
+			}
+			seen[d] = true
+		}
+		if tt.jitter == 0 && len(seen) != 1 {
+			t.Errorf("ttl %s without jitter: got %d distinct TTLs, want 1", tt.ttl, len(seen))
+		}
+		if tt.jitter > 0 && len(seen) < 100 {
+			t.Errorf("ttl %s jitter %v: got only %d distinct TTLs in 1000", tt.ttl, tt.jitter, len(seen))
+		}
+	}
+}
+
+func TestCacheSetSpreadsExpiry(t *testing.T) {
+	c := &readingCache{ttl: time.Hour, jitter: 0.2, entries: map[string]cacheEntry{}}
+
+	before := time.Now()
+	cities := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	for _, city := range cities {
+		c.set("fake", city, observation{kelvin: 290})
+	}
+	after := time.Now()
+
+	expiries := map[time.Time]bool{}
+	for _, city := range cities {
+		e := c.entries[cacheKey("fake", city)]
+		if e.expires.Before(before.Add(48*time.Minute)) || e.expires.After(after.Add(72*time.Minute)) {
+			t.Errorf("%s expires %s after storing, want within 48m to 72m", city, e.expires.Sub(before))
+		}
+		expiries[e.expires] = true
+	}
+	if len(expiries) == 1 {
+		t.Errorf("every entry stored together expires at the same time")
+	}
+}
+
+func TestCacheExpiredEntriesAreRemoved(t *testing.T) {
+	c := &readingCache{ttl: time.Minute, entries: map[string]cacheEntry{}}
+	now := time.Now()
+	c.entries[cacheKey("a", "Paris")] = cacheEntry{obs: observation{kelvin: 280}, expires: now.Add(-time.Second)}
+	c.entries[cacheKey("a", "Rome")] = cacheEntry{obs: observation{kelvin: 290}, expires: now.Add(-time.Second)}
+	c.set("a", "Oslo", observation{kelvin: 270})
+
+	// A lookup drops the expired entry it finds.
+	if _, ok := c.get("a", "Paris"); ok {
+		t.Error("get returned an expired reading")
+	}
+	if _, ok := c.entries[cacheKey("a", "Paris")]; ok {
+		t.Error("get left the expired entry in the cache")
+	}
+
+	tests := []struct {
+		at       time.Time
+		wantKeys int
+	}{
+		{now, 1},
+		{now.Add(2 * time.Minute), 0},
+	}
+
+	for _, tt := range tests {
+		c.sweep(tt.at)
+		if len(c.entries) != tt.wantKeys {
+			t.Errorf("sweep at %s left %d entries, want %d", tt.at.Sub(now), len(c.entries), tt.wantKeys)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// client is shared by every outbound call to a weather or geocoding API.
+var client = http.DefaultClient
+
+// newClient builds an HTTP client with separate limits for connecting,
+// the TLS handshake, waiting for a 100-continue response, waiting for
+// response headers and the request as a whole. A zero timeout means no
+// limit, except for expectContinue where it means the body is sent without
+// waiting.
+func newClient(dial, tlsHandshake, expectContinue, responseHeader, request time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   dial,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = tlsHandshake
+	transport.ExpectContinueTimeout = expectContinue
+	transport.ResponseHeaderTimeout = responseHeader
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   request,
+	}
+}
+
+// fetch GETs url with the shared client. The request is cancelled along
+// with ctx.
+func fetch(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewClientTLSHandshakeTimeout(t *testing.T) {
+	// The listener accepts connections but never answers the TLS handshake.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		var conns []net.Conn
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				for _, c := range conns {
+					c.Close()
+				}
+				return
+			}
+			conns = append(conns, c)
+		}
+	}()
+
+	c := newClient(time.Second, 50*time.Millisecond, time.Second, time.Second, 5*time.Second)
+
+	begin := time.Now()
+	_, err = c.Get("https://" + ln.Addr().String() + "/")
+	if err == nil || !strings.Contains(err.Error(), "TLS handshake timeout") {
+		t.Fatalf("error = %v, want a TLS handshake timeout", err)
+	}
+	if took := time.Since(begin); took > time.Second {
+		t.Errorf("the handshake timed out after %s, want about 50ms", took)
+	}
+}
+
+func TestNewClientTimeouts(t *testing.T) {
+	tests := []struct {
+		expectContinue, responseHeader, request time.Duration
+	}{
+		{time.Second, 10 * time.Second, 15 * time.Second},
+		{250 * time.Millisecond, time.Second, 0},
+		{0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		c := newClient(time.Second, 2*time.Second, tt.expectContinue, tt.responseHeader, tt.request)
+		transport := c.Transport.(*http.Transport)
+		if transport.ExpectContinueTimeout != tt.expectContinue {
+			t.Errorf("ExpectContinueTimeout = %s, want %s", transport.ExpectContinueTimeout, tt.expectContinue)
+		}
+		if transport.ResponseHeaderTimeout != tt.responseHeader {
+			t.Errorf("ResponseHeaderTimeout = %s, want %s", transport.ResponseHeaderTimeout, tt.responseHeader)
+		}
+		if transport.TLSHandshakeTimeout != 2*time.Second {
+			t.Errorf("TLSHandshakeTimeout = %s, want 2s", transport.TLSHandshakeTimeout)
+		}
+		if c.Timeout != tt.request {
+			t.Errorf("Timeout = %s, want %s", c.Timeout, tt.request)
+		}
+	}
+
+	// Each client has its own transport, so tuning one leaves the default
+	// transport alone.
+	if c := newClient(0, 0, time.Minute, 0, 0); c.Transport == http.DefaultTransport {
+		t.Error("newClient reused http.DefaultTransport")
+	}
+}
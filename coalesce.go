@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// coalescingGeocoder shares one lookup between concurrent requests to
+// geocode the same city, so each city is only geocoded once at a time.
+type coalescingGeocoder struct {
+	geocoder
+
+	mu    sync.Mutex
+	calls map[string]*geocodeCall
+}
+
+// geocodeCall is a lookup in flight. done is closed once loc and err are
+// set.
+type geocodeCall struct {
+	done chan struct{}
+	loc  location
+	err  error
+}
+
+func newCoalescingGeocoder(g geocoder) *coalescingGeocoder {
+	return &coalescingGeocoder{geocoder: g, calls: map[string]*geocodeCall{}}
+}
+
+func (c *coalescingGeocoder) geocode(ctx context.Context, city string) (location, error) {
+	key := strings.ToLower(strings.TrimSpace(city))
+
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.loc, call.err
+	}
+	call := &geocodeCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	// The lookup is shared, so it must not be cancelled when the request
+	// that started it goes away.
+	call.loc, call.err = c.geocoder.geocode(context.WithoutCancel(ctx), city)
+	close(call.done)
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+
+	return call.loc, call.err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingGeocoder counts lookups and holds each one until release is
+// closed.
+type blockingGeocoder struct {
+	release chan struct{}
+	calls   int64
+}
+
+func (g *blockingGeocoder) geocode(ctx context.Context, city string) (location, error) {
+	atomic.AddInt64(&g.calls, 1)
+	<-g.release
+	return location{Name: city, Country: "GB"}, nil
+}
+
+func TestCoalescingGeocoder(t *testing.T) {
+	tests := []struct {
+		cities    []string
+		wantCalls int64
+	}{
+		{[]string{"London", "London", "London"}, 1},
+		{[]string{"London", " london", "LONDON "}, 1},
+		{[]string{"London", "Paris", "London"}, 2},
+	}
+
+	for _, tt := range tests {
+		inner := &blockingGeocoder{release: make(chan struct{})}
+		c := newCoalescingGeocoder(inner)
+
+		var wg sync.WaitGroup
+		for _, city := range tt.cities {
+			wg.Add(1)
+			go func(city string) {
+				defer wg.Done()
+				if l, err := c.geocode(context.Background(), city); err != nil || l.Country != "GB" {
+					t.Errorf("geocode(%q) = %v, %v", city, l, err)
+				}
+			}(city)
+		}
+
+		// Let every caller join a lookup before any finishes.
+		time.Sleep(20 * time.Millisecond)
+		close(inner.release)
+		wg.Wait()
+
+		if inner.calls != tt.wantCalls {
+			t.Errorf("%q: %d lookups, want %d", tt.cities, inner.calls, tt.wantCalls)
+		}
+	}
+}
+
+func TestCoveringGeocodesOnce(t *testing.T) {
+	inner := &blockingGeocoder{release: make(chan struct{})}
+	close(inner.release)
+	usePlaces(t, newCoalescingGeocoder(inner))
+
+	group := multiWeatherProvider{
+		&fakeProvider{id: "uk", region: []string{"GB"}},
+		&fakeProvider{id: "europe", region: []string{"GB", "FR", "DE"}},
+		&fakeProvider{id: "us", region: []string{"US"}},
+	}
+	covering := group.covering(context.Background(), "London")
+
+	if inner.calls != 1 {
+		t.Errorf("geocoded London %d times for three providers, want once", inner.calls)
+	}
+	if len(covering) != 2 || covering[0].name() != "uk" || covering[1].name() != "europe" {
+		t.Errorf("covering London = %v, want uk and europe", covering)
+	}
+}
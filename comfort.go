@@ -0,0 +1,60 @@
+package main
+
+import "math"
+
+// comfort returns the apparent temperature in Fahrenheit: the heat index
+// when it is hot and humidity is known, or the wind chill when it is cold
+// and wind speed (in mph) is known. The name of the formula applied is
+// returned alongside it. It returns false when neither applies.
+func comfort(tempF, humidity float64, hasHumidity bool, windMph float64, hasWind bool) (float64, string, bool) {
+	switch {
+	case hasHumidity && tempF >= 80:
+		return heatIndex(tempF, humidity), "heat_index", true
+	case hasWind && tempF <= 50 && windMph >= 3:
+		return windChill(tempF, windMph), "wind_chill", true
+	}
+	return 0, "", false
+}
+
+// heatIndex computes the NWS heat index in Fahrenheit from the temperature
+// in Fahrenheit and relative humidity in percent, using the Rothfusz
+// regression and its low and high humidity adjustments. Below 80°F the
+// simpler Steadman formula is used, as the NWS does.
+func heatIndex(t, rh float64) float64 {
+	simple := 0.5 * (t + 61 + (t-68)*1.2 + rh*0.094)
+	if (simple+t)/2 < 80 {
+		return simple
+	}
+
+	hi := -42.379 + 2.04901523*t + 10.14333127*rh -
+		0.22475541*t*rh - 0.00683783*t*t - 0.05481717*rh*rh +
+		0.00122874*t*t*rh + 0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+
+	switch {
+	case rh < 13 && t >= 80 && t <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+	case rh > 85 && t >= 80 && t <= 87:
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+	return hi
+}
+
+// windChill computes the NWS wind chill in Fahrenheit from the temperature
+// in Fahrenheit and wind speed in mph. It is only defined for temperatures
+// at or below 50°F and wind speeds of at least 3 mph.
+func windChill(t, v float64) float64 {
+	p := math.Pow(v, 0.16)
+	return 35.74 + 0.6215*t - 35.75*p + 0.4275*t*p
+}
+
+// wetBulb estimates the wet-bulb temperature in Celsius from the temperature
+// in Celsius and relative humidity in percent, using Stull's (2011)
+// approximation. It is accurate to within about 1°C for humidity between 5%
+// and 99% and temperatures between -20°C and 50°C, at sea-level pressure.
+func wetBulb(tempC, humidityPct float64) float64 {
+	t, rh := tempC, humidityPct
+	return t*math.Atan(0.151977*math.Sqrt(rh+8.313659)) +
+		math.Atan(t+rh) - math.Atan(rh-1.676331) +
+		0.00391838*math.Pow(rh, 1.5)*math.Atan(0.023101*rh) -
+		4.686035
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// The reference values are from the NWS heat index and wind chill charts,
+// which are rounded to the nearest degree.
+
+func TestHeatIndex(t *testing.T) {
+	tests := []struct {
+		tempF, humidity float64
+		want            float64
+	}{
+		{80, 40, 80},
+		{86, 90, 105},
+		{90, 40, 91},
+		{90, 50, 95},
+		{90, 70, 106},
+		{96, 40, 101},
+		{96, 65, 121},
+		{100, 40, 109},
+	}
+
+	for _, tt := range tests {
+		if got := heatIndex(tt.tempF, tt.humidity); math.Round(got) != tt.want {
+			t.Errorf("heatIndex(%v°F, %v%%) = %.1f, want %v", tt.tempF, tt.humidity, got, tt.want)
+		}
+	}
+}
+
+func TestWindChill(t *testing.T) {
+	tests := []struct {
+		tempF, windMph float64
+		want           float64
+	}{
+		{40, 5, 36},
+		{40, 10, 34},
+		{30, 10, 21},
+		{20, 30, 1},
+		{5, 25, -17},
+		{0, 15, -19},
+		{-10, 20, -35},
+		{-20, 40, -57},
+	}
+
+	for _, tt := range tests {
+		if got := windChill(tt.tempF, tt.windMph); math.Round(got) != tt.want {
+			t.Errorf("windChill(%v°F, %v mph) = %.1f, want %v", tt.tempF, tt.windMph, got, tt.want)
+		}
+	}
+}
+
+func TestComfort(t *testing.T) {
+	tests := []struct {
+		name        string
+		tempF       float64
+		humidity    float64
+		hasHumidity bool
+		windMph     float64
+		hasWind     bool
+		wantFormula string
+	}{
+		{"hot and humid", 90, 50, true, 10, true, "heat_index"},
+		{"hot without humidity", 90, 0, false, 10, true, ""},
+		{"cold and windy", 30, 50, true, 10, true, "wind_chill"},
+		{"cold and calm", 30, 50, true, 2, true, ""},
+		{"cold without wind", 30, 50, true, 0, false, ""},
+		{"mild", 65, 50, true, 10, true, ""},
+	}
+
+	for _, tt := range tests {
+		_, formula, ok := comfort(tt.tempF, tt.humidity, tt.hasHumidity, tt.windMph, tt.hasWind)
+		if formula != tt.wantFormula || ok != (tt.wantFormula != "") {
+			t.Errorf("%s: formula = %q, %v, want %q", tt.name, formula, ok, tt.wantFormula)
+		}
+	}
+}
+
+func TestWetBulb(t *testing.T) {
+	tests := []struct {
+		tempC, humidity float64
+		want, tolerance float64
+	}{
+		{20, 50, 13.7, 0.05}, // Stull's worked example
+		// Saturated air cannot be cooled by evaporation.
+		{20, 100, 20, 0.05},
+		{5, 100, 5, 0.1},
+		// Psychrometric chart values at sea-level pressure.
+		{30, 60, 23.9, 0.3},
+		{35, 75, 31.0, 0.3},
+		{25, 20, 12.6, 0.3},
+	}
+
+	for _, tt := range tests {
+		if got := wetBulb(tt.tempC, tt.humidity); math.Abs(got-tt.want) > tt.tolerance {
+			t.Errorf("wetBulb(%v°C, %v%%) = %.2f, want %v ± %v", tt.tempC, tt.humidity, got, tt.want, tt.tolerance)
+		}
+	}
+}
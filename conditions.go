@@ -0,0 +1,111 @@
+package main
+
+import "strings"
+
+// Normalized weather conditions, shared by every provider.
+const (
+	conditionClear        = "clear"
+	conditionPartlyCloudy = "partly_cloudy"
+	conditionCloudy       = "cloudy"
+	conditionRain         = "rain"
+	conditionSnow         = "snow"
+	conditionSleet        = "sleet"
+	conditionFog          = "fog"
+	conditionThunderstorm = "thunderstorm"
+	conditionUnknown      = "unknown"
+)
+
+// owmConditions maps OpenWeatherMap's weather descriptions and main groups
+// to normalized conditions. Descriptions are checked before groups so that
+// "few clouds" and "overcast clouds" can be told apart.
+var owmConditions = map[string]string{
+	"few clouds":       conditionPartlyCloudy,
+	"scattered clouds": conditionPartlyCloudy,
+	"broken clouds":    conditionCloudy,
+	"overcast clouds":  conditionCloudy,
+	"clear":            conditionClear,
+	"clouds":           conditionCloudy,
+	"drizzle":          conditionRain,
+	"rain":             conditionRain,
+	"snow":             conditionSnow,
+	"sleet":            conditionSleet,
+	"mist":             conditionFog,
+	"fog":              conditionFog,
+	"haze":             conditionFog,
+	"smoke":            conditionFog,
+	"thunderstorm":     conditionThunderstorm,
+}
+
+// wuConditions maps Weather Underground's condition phrases, without any
+// "Light" or "Heavy" prefix, to normalized conditions.
+var wuConditions = map[string]string{
+	"clear":                    conditionClear,
+	"mostly sunny":             conditionPartlyCloudy,
+	"partly sunny":             conditionPartlyCloudy,
+	"partly cloudy":            conditionPartlyCloudy,
+	"scattered clouds":         conditionPartlyCloudy,
+	"mostly cloudy":            conditionCloudy,
+	"cloudy":                   conditionCloudy,
+	"overcast":                 conditionCloudy,
+	"drizzle":                  conditionRain,
+	"rain":                     conditionRain,
+	"rain showers":             conditionRain,
+	"snow":                     conditionSnow,
+	"snow showers":             conditionSnow,
+	"ice pellets":              conditionSleet,
+	"freezing rain":            conditionSleet,
+	"mist":                     conditionFog,
+	"fog":                      conditionFog,
+	"haze":                     conditionFog,
+	"thunderstorm":             conditionThunderstorm,
+	"thunderstorms":            conditionThunderstorm,
+	"thunderstorms and rain":   conditionThunderstorm,
+	"chance of a thunderstorm": conditionThunderstorm,
+}
+
+// normalizeCondition looks up the first of the raw phrases found in table,
+// ignoring case and intensity prefixes.
+func normalizeCondition(table map[string]string, raw ...string) string {
+	for _, r := range raw {
+		r = strings.ToLower(strings.TrimSpace(r))
+		r = strings.TrimPrefix(r, "light ")
+		r = strings.TrimPrefix(r, "heavy ")
+		if c, ok := table[r]; ok {
+			return c
+		}
+	}
+	return conditionUnknown
+}
+
+// prevailingCondition returns the normalized condition reported by the most
+// providers, or false when none reported one.
+func prevailingCondition(obs []observation) (string, bool) {
+	counts := map[string]int{}
+	for _, o := range obs {
+		if o.condition != "" {
+			counts[o.condition]++
+		}
+	}
+
+	best := ""
+	for c, n := range counts {
+		if n > counts[best] || (n == counts[best] && c < best) {
+			best = c
+		}
+	}
+	return best, best != ""
+}
+
+// precipitationChance returns the chance of precipitation implied by a
+// current normalized condition: certain while it is raining or snowing, none
+// otherwise. It returns nil when the condition is unknown.
+func precipitationChance(condition string) *float64 {
+	var chance float64
+	switch condition {
+	case conditionUnknown:
+		return nil
+	case conditionRain, conditionSnow, conditionSleet, conditionThunderstorm:
+		chance = 100
+	}
+	return &chance
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestNormalizeCondition(t *testing.T) {
+	tests := []struct {
+		provider string
+		table    map[string]string
+		raw      []string
+		want     string
+	}{
+		{"openweathermap", owmConditions, []string{"few clouds", "Clouds"}, conditionPartlyCloudy},
+		{"openweathermap", owmConditions, []string{"overcast clouds", "Clouds"}, conditionCloudy},
+		{"openweathermap", owmConditions, []string{"moderate rain", "Rain"}, conditionRain},
+		{"openweathermap", owmConditions, []string{"clear sky", "Clear"}, conditionClear},
+		{"openweathermap", owmConditions, []string{"thunderstorm with light rain", "Thunderstorm"}, conditionThunderstorm},
+		{"openweathermap", owmConditions, []string{"volcanic ash", "Ash"}, conditionUnknown},
+		{"weatherunderground", wuConditions, []string{"Partly Cloudy"}, conditionPartlyCloudy},
+		{"weatherunderground", wuConditions, []string{"Mostly Cloudy"}, conditionCloudy},
+		{"weatherunderground", wuConditions, []string{"Light Rain Showers"}, conditionRain},
+		{"weatherunderground", wuConditions, []string{"Heavy Snow"}, conditionSnow},
+		{"weatherunderground", wuConditions, []string{"Freezing Rain"}, conditionSleet},
+		{"weatherunderground", wuConditions, []string{" Fog "}, conditionFog},
+		{"weatherunderground", wuConditions, []string{"Funnel Cloud"}, conditionUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeCondition(tt.table, tt.raw...); got != tt.want {
+			t.Errorf("%s %q = %s, want %s", tt.provider, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestPrevailingCondition(t *testing.T) {
+	obs := func(conditions ...string) []observation {
+		var list []observation
+		for _, c := range conditions {
+			list = append(list, observation{condition: c})
+		}
+		return list
+	}
+
+	tests := []struct {
+		obs    []observation
+		want   string
+		wantOK bool
+	}{
+		{obs(conditionRain, conditionCloudy, conditionRain), conditionRain, true},
+		{obs(conditionRain, conditionCloudy), conditionCloudy, true}, // ties go to the first alphabetically
+		{obs("", conditionFog), conditionFog, true},
+		{obs("", ""), "", false},
+		{nil, "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := prevailingCondition(tt.obs)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("prevailingCondition(%v) = %q, %v, want %q, %v", tt.obs, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
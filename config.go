@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"strings"
+)
+
+// configHandler writes the effective configuration as JSON, with secrets
+// redacted. It is only available from localhost.
+func configHandler(writer http.ResponseWriter, req *http.Request) {
+	if !isLocal(req) {
+		writeError(writer, errForbidden, "forbidden")
+		return
+	}
+
+	flags := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"flags": flags,
+		"keys": map[string]string{
+			"weatherunderground": redact(wuKey),
+		},
+	})
+}
+
+// redact hides a secret, keeping its last four characters when it is long
+// enough that they give nothing away.
+func redact(secret string) string {
+	secret = strings.TrimSpace(secret)
+	switch {
+	case secret == "":
+		return ""
+	case len(secret) < 12:
+		return "***"
+	}
+	return "***" + secret[len(secret)-4:]
+}
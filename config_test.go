@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		secret, want string
+	}{
+		{"", ""},
+		{"short", "***"},
+		{"elevenchars", "***"},
+		{"0123456789abcdef", "***cdef"},
+		{" 0123456789abcdef\n", "***cdef"},
+	}
+
+	for _, tt := range tests {
+		if got := redact(tt.secret); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.secret, got, tt.want)
+		}
+	}
+}
+
+func TestConfigHandlerRedactsKeys(t *testing.T) {
+	defer func(key string) { wuKey = key }(wuKey)
+	wuKey = "0123456789abcdef\n"
+
+	tests := []struct {
+		remote     string
+		wantStatus int
+	}{
+		{"127.0.0.1:5000", http.StatusOK},
+		{"203.0.113.9:5000", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/config", nil)
+		req.RemoteAddr = tt.remote
+		rec := httptest.NewRecorder()
+		configHandler(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("from %s: status = %d, want %d", tt.remote, rec.Code, tt.wantStatus)
+		}
+		if strings.Contains(rec.Body.String(), "0123456789") {
+			t.Errorf("from %s: the key leaked: %s", tt.remote, rec.Body)
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+
+		var body struct {
+			Keys map[string]string `json:"keys"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if got := body.Keys["weatherunderground"]; got != "***cdef" {
+			t.Errorf("weatherunderground key = %q, want ***cdef", got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// cooldowns records providers that asked, through Retry-After, not to be
+// called again until a given time. Every request skips them until then.
+var cooldowns = &cooldownList{until: map[string]time.Time{}}
+
+type cooldownList struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// active returns the time the provider's cool-down ends, if it has one.
+func (c *cooldownList) active(provider string) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until, ok := c.until[provider]
+	if !ok {
+		return time.Time{}, false
+	}
+	if time.Now().After(until) {
+		delete(c.until, provider)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// set starts a cool-down for the provider lasting until the given time.
+func (c *cooldownList) set(provider string, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.until[provider] = until
+}
+
+// retryAfterError is returned by a provider that was told to back off.
+type retryAfterError struct {
+	provider string
+	until    time.Time
+}
+
+func (e *retryAfterError) Error() string {
+	return fmt.Sprintf("%s asked to retry after %s", e.provider, e.until.Format(time.RFC3339))
+}
+
+// checkRetryAfter returns a retryAfterError when resp is a 429 or 503 with a
+// Retry-After header, given either in seconds or as an HTTP date.
+func checkRetryAfter(provider string, resp *http.Response) error {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil
+	}
+
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return nil
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return &retryAfterError{provider, time.Now().Add(time.Duration(seconds) * time.Second)}
+	}
+	if until, err := http.ParseTime(header); err == nil {
+		return &retryAfterError{provider, until}
+	}
+	return nil
+}
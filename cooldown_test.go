@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCheckRetryAfter(t *testing.T) {
+	date := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		status     int
+		retryAfter string
+		wantUntil  time.Time // zero when no cool-down is wanted
+	}{
+		{http.StatusTooManyRequests, "120", time.Now().Add(2 * time.Minute)},
+		{http.StatusServiceUnavailable, date.Format(http.TimeFormat), date},
+		{http.StatusTooManyRequests, "", time.Time{}},
+		{http.StatusTooManyRequests, "soon", time.Time{}},
+		{http.StatusOK, "120", time.Time{}},
+		{http.StatusInternalServerError, "120", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if tt.retryAfter != "" {
+			resp.Header.Set("Retry-After", tt.retryAfter)
+		}
+
+		err := checkRetryAfter("fake", resp)
+		ra, ok := err.(*retryAfterError)
+		if ok != !tt.wantUntil.IsZero() {
+			t.Errorf("%d with Retry-After %q: error = %v, want a cool-down %v", tt.status, tt.retryAfter, err, !tt.wantUntil.IsZero())
+			continue
+		}
+		if ok && ra.until.Sub(tt.wantUntil).Abs() > time.Second {
+			t.Errorf("%d with Retry-After %q: cool-down until %s, want %s", tt.status, tt.retryAfter, ra.until, tt.wantUntil)
+		}
+	}
+}
+
+func TestCooldownSkipsProviderUntilExpiry(t *testing.T) {
+	saved := cooldowns
+	cooldowns = &cooldownList{until: map[string]time.Time{}}
+	defer func() { cooldowns = saved }()
+
+	limited := &fakeProvider{id: "limited", err: &retryAfterError{"limited", time.Now().Add(100 * time.Millisecond)}}
+	other := reading("other", 290)
+	group := multiWeatherProvider{limited, other}
+
+	steps := []struct {
+		wait      time.Duration
+		wantCalls int64
+	}{
+		{0, 1}, // answers 429 and starts the cool-down
+		{0, 1}, // skipped
+		{0, 1}, // still skipped
+		{150 * time.Millisecond, 2},
+	}
+
+	for i, step := range steps {
+		time.Sleep(step.wait)
+		obs, err := group.readings(context.Background(), "Paris")
+		if err != nil || len(obs) != 1 {
+			t.Fatalf("request %d: got %d readings and %v, want the other provider's", i+1, len(obs), err)
+		}
+		if limited.calls != step.wantCalls {
+			t.Errorf("request %d: the limited provider was called %d times, want %d", i+1, limited.calls, step.wantCalls)
+		}
+	}
+	if other.calls != int64(len(steps)) {
+		t.Errorf("the other provider was called %d times, want %d", other.calls, len(steps))
+	}
+}
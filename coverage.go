@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// covers reports whether a provider with the given coverage, a list of ISO
+// 3166-1 alpha-2 country codes, serves country. An empty coverage means the
+// provider is global.
+func covers(coverage []string, country string) bool {
+	if len(coverage) == 0 {
+		return true
+	}
+	for _, c := range coverage {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+	return false
+}
+
+// covering returns the providers whose coverage includes the city's
+// country. The city is only geocoded when some provider is regional, and
+// every provider is kept when it cannot be geocoded.
+func (w multiWeatherProvider) covering(ctx context.Context, city string) multiWeatherProvider {
+	regional := false
+	for _, p := range w {
+		if len(p.coverage()) > 0 {
+			regional = true
+			break
+		}
+	}
+	if !regional {
+		return w
+	}
+
+	l, err := places.geocode(ctx, city)
+	if err != nil {
+		fmt.Printf("Unable to find the country of %s, using every provider: %v\n", city, err)
+		return w
+	}
+
+	var covering multiWeatherProvider
+	for _, p := range w {
+		if covers(p.coverage(), l.Country) {
+			covering = append(covering, p)
+		} else {
+			logf(ctx, "Skipping %s, which does not cover %s\n", p.name(), l.Country)
+		}
+	}
+	return covering
+}
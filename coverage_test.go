@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		coverage []string
+		country  string
+		want     bool
+	}{
+		{nil, "FR", true},
+		{[]string{"US"}, "US", true},
+		{[]string{"US", "PR"}, "pr", true},
+		{[]string{"US"}, "FR", false},
+		{[]string{"US"}, "", false},
+	}
+
+	for _, tt := range tests {
+		if got := covers(tt.coverage, tt.country); got != tt.want {
+			t.Errorf("covers(%v, %q) = %v, want %v", tt.coverage, tt.country, got, tt.want)
+		}
+	}
+}
+
+func TestWeatherSkipsRegionalProviders(t *testing.T) {
+	tests := []struct {
+		name   string
+		geo    *fakeGeocoder
+		wantUS bool
+	}{
+		{"European city", &fakeGeocoder{loc: location{Country: "FR"}}, false},
+		{"American city", &fakeGeocoder{loc: location{Country: "US"}}, true},
+		// Every provider is kept when the country is unknown.
+		{"unknown city", &fakeGeocoder{err: errors.New("unable to find Atlantis")}, true},
+	}
+
+	for _, tt := range tests {
+		usOnly := &fakeProvider{id: "us-only", obs: observation{kelvin: 300}, region: []string{"US"}}
+		global := reading("global", 290)
+		useGroups(t, multiWeatherProvider{usOnly, global})
+		usePlaces(t, tt.geo)
+
+		rec, _ := getWeather(t, "/weather/Paris", nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", tt.name, rec.Code)
+		}
+		if asked := usOnly.calls > 0; asked != tt.wantUS {
+			t.Errorf("%s: US-only provider asked = %v, want %v", tt.name, asked, tt.wantUS)
+		}
+		if global.calls != 1 {
+			t.Errorf("%s: global provider called %d times, want once", tt.name, global.calls)
+		}
+	}
+}
+
+func TestWeatherWithoutCoveringProvider(t *testing.T) {
+	useGroups(t, multiWeatherProvider{&fakeProvider{id: "us-only", region: []string{"US"}}})
+	usePlaces(t, &fakeGeocoder{loc: location{Country: "FR"}})
+
+	rec, body := getWeather(t, "/weather/Paris", nil)
+	if rec.Code != errUpstream.Status || body["code"] != errUpstream.Code {
+		t.Errorf("status %d with %v, want %s", rec.Code, body, errUpstream.Code)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// previous remembers the last temperature each client was given for each
+// city, so polling clients can be told how much it changed.
+var previous = &previousReadings{window: 10 * time.Minute, last: map[string]previousReading{}}
+
+type previousReading struct {
+	kelvin float64
+	at     time.Time
+}
+
+type previousReadings struct {
+	mu     sync.Mutex
+	window time.Duration
+	last   map[string]previousReading
+}
+
+// clientToken identifies the client for delta_since_last: the
+// X-Client-Token header, or failing that X-Request-ID.
+func clientToken(req *http.Request) string {
+	if t := req.Header.Get("X-Client-Token"); t != "" {
+		return t
+	}
+	return req.Header.Get("X-Request-ID")
+}
+
+// swap stores kelvin as the client's latest reading for city and returns the
+// one it replaces, if that is within the window.
+func (p *previousReadings) swap(client, city string, kelvin float64) (float64, bool) {
+	if client == "" || p.window <= 0 {
+		return 0, false
+	}
+
+	key := client + "|" + strings.ToLower(strings.TrimSpace(city))
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prev, ok := p.last[key]
+	p.last[key] = previousReading{kelvin, now}
+	if ok && now.Sub(prev.at) > p.window {
+		return 0, false
+	}
+	return prev.kelvin, ok
+}
+
+// sweep forgets the readings older than the window, so clients that stop
+// polling do not stay in memory.
+func (p *previousReadings) sweep(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for k, r := range p.last {
+		if now.Sub(r.at) > p.window {
+			delete(p.last, k)
+		}
+	}
+}
+
+// sweepEvery sweeps the readings every interval, for as long as the server
+// runs.
+func (p *previousReadings) sweepEvery(interval time.Duration) {
+	for now := range time.Tick(interval) {
+		p.sweep(now)
+	}
+}
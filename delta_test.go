@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPreviousReadingsSwap(t *testing.T) {
+	p := &previousReadings{window: time.Minute, last: map[string]previousReading{}}
+
+	tests := []struct {
+		client, city string
+		kelvin       float64
+		wantPrev     float64
+		wantOK       bool
+	}{
+		{"alice", "London", 280, 0, false},
+		{"alice", " london ", 282, 280, true},
+		{"alice", "London", 281, 282, true},
+		{"bob", "London", 290, 0, false},
+		{"alice", "Paris", 285, 0, false},
+		{"", "London", 280, 0, false},
+	}
+
+	for _, tt := range tests {
+		prev, ok := p.swap(tt.client, tt.city, tt.kelvin)
+		if prev != tt.wantPrev || ok != tt.wantOK {
+			t.Errorf("swap(%q, %q, %v) = %v, %v, want %v, %v", tt.client, tt.city, tt.kelvin, prev, ok, tt.wantPrev, tt.wantOK)
+		}
+	}
+}
+
+func TestPreviousReadingsExpiry(t *testing.T) {
+	p := &previousReadings{window: time.Minute, last: map[string]previousReading{}}
+	p.swap("alice", "London", 280)
+	p.swap("bob", "London", 290)
+	p.last["alice|london"] = previousReading{280, time.Now().Add(-2 * time.Minute)}
+
+	// An expired reading is not reported even before a sweep removes it.
+	if prev, ok := p.swap("alice", "London", 281); ok {
+		t.Errorf("swap after the window = %v, want no previous reading", prev)
+	}
+	if prev, ok := p.swap("alice", "London", 283); !ok || prev != 281 {
+		t.Errorf("swap within the window = %v, %v, want 281", prev, ok)
+	}
+
+	p.sweep(time.Now().Add(2 * time.Minute))
+	if len(p.last) != 0 {
+		t.Errorf("%d readings left after a sweep past the window, want 0", len(p.last))
+	}
+}
+
+func TestClientToken(t *testing.T) {
+	tests := []struct {
+		token, requestID string
+		want             string
+	}{
+		{"t1", "r1", "t1"},
+		{"", "r1", "r1"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "/weather/London", nil)
+		req.Header.Set("X-Client-Token", tt.token)
+		req.Header.Set("X-Request-ID", tt.requestID)
+		if got := clientToken(req); got != tt.want {
+			t.Errorf("clientToken(%q, %q) = %q, want %q", tt.token, tt.requestID, got, tt.want)
+		}
+	}
+}
+
+func TestWeatherDeltaSinceLast(t *testing.T) {
+	defer func(p *previousReadings) { previous = p }(previous)
+	useCacheTTL(t, 0)
+
+	tests := []struct {
+		units     string
+		wantDelta float64
+	}{
+		{"c", 2},
+		{"f", 3.6},
+		{"k", 2},
+	}
+
+	for _, tt := range tests {
+		previous = &previousReadings{window: time.Minute, last: map[string]previousReading{}}
+		p := reading("fake", 280)
+		useGroups(t, multiWeatherProvider{p})
+		path := "/weather/Madrid?units=" + tt.units
+		alice := http.Header{"X-Client-Token": {"alice"}}
+
+		_, first := getWeather(t, path, alice)
+		if d, ok := first["delta_since_last"]; ok {
+			t.Errorf("%s: first response has delta_since_last %v", tt.units, d)
+		}
+
+		p.obs.kelvin = 282
+		_, second := getWeather(t, path, alice)
+		if d := second["delta_since_last"]; d != tt.wantDelta {
+			t.Errorf("%s: second response has delta_since_last %v, want %v", tt.units, d, tt.wantDelta)
+		}
+
+		// Another client's first request has nothing to compare with.
+		_, other := getWeather(t, path, http.Header{"X-Client-Token": {"bob"}})
+		if d, ok := other["delta_since_last"]; ok {
+			t.Errorf("%s: another client's first response has delta_since_last %v", tt.units, d)
+		}
+	}
+}
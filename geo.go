@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// A geolocator resolves a client IP address to the name of a city.
+type geolocator interface {
+	city(ctx context.Context, ip string) (string, error)
+}
+
+// ipAPI looks up IP addresses against an ip-api.com compatible service.
+// The IP address is appended to url.
+type ipAPI struct {
+	url string
+}
+
+// geo is used by /weather/here. It is nil when geolocation is disabled.
+var geo geolocator
+
+func (g ipAPI) city(ctx context.Context, ip string) (string, error) {
+	resp, err := fetch(ctx, g.url+ip)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	var d struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		City    string `json:"city"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return "", err
+	}
+
+	if d.Status != "success" || d.City == "" {
+		return "", fmt.Errorf("unable to geolocate %s: %s", ip, d.Message)
+	}
+
+	logf(ctx, "Geolocated %s to %s\n", ip, d.City)
+
+	return d.City, nil
+}
+
+// clientIP returns the address of the client that made the request. When the
+// request came through a proxy, the first address in X-Forwarded-For is used.
+func clientIP(req *http.Request) string {
+	if fwd := req.Header.Get("X-Forwarded-For"); fwd != "" {
+		return strings.TrimSpace(strings.SplitN(fwd, ",", 2)[0])
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// locate returns the city of the client that made the request.
+func locate(req *http.Request) (string, error) {
+	if geo == nil {
+		return "", errors.New("geolocation is not configured")
+	}
+	ip := clientIP(req)
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("%q is not a valid IP address", ip)
+	}
+	return geo.city(req.Context(), ip)
+}
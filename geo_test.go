@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeGeolocator resolves the IP addresses in cities, and records the last
+// one it was asked about.
+type fakeGeolocator struct {
+	cities map[string]string
+	asked  string
+}
+
+func (g *fakeGeolocator) city(ctx context.Context, ip string) (string, error) {
+	g.asked = ip
+	if c, ok := g.cities[ip]; ok {
+		return c, nil
+	}
+	return "", fmt.Errorf("unable to geolocate %s", ip)
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remote, forwarded string
+		want              string
+	}{
+		{"198.51.100.7:5000", "", "198.51.100.7"},
+		{"10.0.0.1:5000", "203.0.113.9", "203.0.113.9"},
+		{"10.0.0.1:5000", " 203.0.113.9 , 10.0.0.2", "203.0.113.9"},
+		{"[2001:db8::1]:5000", "", "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/weather/here", nil)
+		req.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := clientIP(req); got != tt.want {
+			t.Errorf("clientIP(%q, X-Forwarded-For %q) = %q, want %q", tt.remote, tt.forwarded, got, tt.want)
+		}
+	}
+}
+
+func TestWeatherHere(t *testing.T) {
+	defer func(g geolocator) { geo = g }(geo)
+
+	tests := []struct {
+		name       string
+		geo        *fakeGeolocator
+		forwarded  string
+		wantStatus int
+		wantCity   string
+	}{
+		{"known IP", &fakeGeolocator{cities: map[string]string{"203.0.113.9": "Lyon"}}, "203.0.113.9", http.StatusOK, "Lyon"},
+		{"unknown IP", &fakeGeolocator{}, "203.0.113.10", http.StatusServiceUnavailable, ""},
+		{"invalid IP", &fakeGeolocator{}, "not-an-ip/../x", http.StatusServiceUnavailable, ""},
+		{"disabled", nil, "203.0.113.9", http.StatusServiceUnavailable, ""},
+	}
+
+	for _, tt := range tests {
+		p := reading("fake", 290)
+		useGroups(t, multiWeatherProvider{p})
+		geo = nil
+		if tt.geo != nil {
+			geo = tt.geo
+		}
+
+		rec, body := getWeather(t, "/weather/here", http.Header{"X-Forwarded-For": {tt.forwarded}})
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantCity != "" {
+			if body["city"] != tt.wantCity {
+				t.Errorf("%s: city = %v, want %s", tt.name, body["city"], tt.wantCity)
+			}
+			continue
+		}
+		if body["code"] != errGeolocation.Code {
+			t.Errorf("%s: code = %v, want %s", tt.name, body["code"], errGeolocation.Code)
+		}
+		if len(p.cities) != 0 {
+			t.Errorf("%s: queried %v, want nothing", tt.name, p.cities)
+		}
+		if tt.name == "invalid IP" && tt.geo.asked != "" {
+			t.Errorf("%s: the geolocator was asked about %q", tt.name, tt.geo.asked)
+		}
+	}
+}
+
+func TestIPAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, "/json/") == "203.0.113.9" {
+			fmt.Fprint(w, `{"status":"success","city":"Lyon"}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":"fail","message":"reserved range"}`)
+	}))
+	defer server.Close()
+
+	g := ipAPI{url: server.URL + "/json/"}
+	if city, err := g.city(context.Background(), "203.0.113.9"); err != nil || city != "Lyon" {
+		t.Errorf("city(203.0.113.9) = %q, %v, want Lyon", city, err)
+	}
+	if _, err := g.city(context.Background(), "10.0.0.1"); err == nil || !strings.Contains(err.Error(), "reserved range") {
+		t.Errorf("city(10.0.0.1) error = %v, want the service's message", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// providers lists every provider that can be named in -provider-groups.
+var providers = map[string]weatherProvider{
+	"openweathermap":     openWeatherMap{},
+	"weatherunderground": weatherUnderground{},
+}
+
+// Ways to handle a provider named more than once in -provider-groups.
+const (
+	duplicatesStrict = "strict" // refuse to start
+	duplicatesFirst  = "first"  // keep only the first mention
+)
+
+// providerGroups is an ordered list of fallback groups. Readings are
+// averaged within a group, and the next group is only queried when every
+// provider in the previous group failed.
+type providerGroups []multiWeatherProvider
+
+// readings returns the readings from the first group that produced any.
+func (g providerGroups) readings(ctx context.Context, city string) ([]observation, error) {
+	err := errors.New("no weather providers configured")
+	for i, group := range g {
+		var obs []observation
+		if obs, err = group.readings(ctx, city); err == nil {
+			return obs, nil
+		}
+		fmt.Printf("Provider group %d failed for %s, trying the next group\n", i+1, city)
+	}
+	return nil, err
+}
+
+// parseProviderGroups parses a list of groups separated by semicolons, each
+// a list of provider names separated by commas, e.g.
+// "openweathermap;weatherunderground". A provider named more than once is
+// handled according to duplicates.
+func parseProviderGroups(spec, duplicates string) (providerGroups, error) {
+	if duplicates != duplicatesStrict && duplicates != duplicatesFirst {
+		return nil, fmt.Errorf("unknown duplicate provider handling %q", duplicates)
+	}
+
+	var groups providerGroups
+	seen := map[string]bool{}
+	for _, names := range strings.Split(spec, ";") {
+		var group multiWeatherProvider
+		for _, name := range strings.Split(names, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			p, ok := providers[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown weather provider %q", name)
+			}
+			if seen[name] {
+				if duplicates == duplicatesStrict {
+					return nil, fmt.Errorf("weather provider %q is listed more than once", name)
+				}
+				fmt.Printf("Weather provider %s is listed more than once, ignoring the repeat\n", name)
+				continue
+			}
+			seen[name] = true
+			group = append(group, p)
+		}
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+
+	if len(groups) == 0 {
+		return nil, errors.New("at least one weather provider must be configured")
+	}
+	return groups, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestProviderGroupsFailover(t *testing.T) {
+	tests := []struct {
+		name         string
+		first        multiWeatherProvider
+		fallback     *fakeProvider
+		wantKelvin   []float64
+		wantFallback bool
+		wantErr      bool
+	}{
+		{
+			name:       "first group answers",
+			first:      multiWeatherProvider{reading("a", 280), reading("b", 282)},
+			fallback:   reading("c", 300),
+			wantKelvin: []float64{280, 282},
+		},
+		{
+			name:       "first group partly fails",
+			first:      multiWeatherProvider{reading("a", 280), failing("b")},
+			fallback:   reading("c", 300),
+			wantKelvin: []float64{280},
+		},
+		{
+			name:         "first group fully fails",
+			first:        multiWeatherProvider{failing("a"), failing("b")},
+			fallback:     reading("c", 300),
+			wantKelvin:   []float64{300},
+			wantFallback: true,
+		},
+		{
+			name:         "every group fails",
+			first:        multiWeatherProvider{failing("a")},
+			fallback:     failing("c"),
+			wantFallback: true,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		g := providerGroups{tt.first, {tt.fallback}}
+		obs, err := g.readings(context.Background(), "Paris")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+
+		got := map[float64]bool{}
+		for _, o := range obs {
+			got[o.kelvin] = true
+		}
+		if len(got) != len(tt.wantKelvin) {
+			t.Errorf("%s: got readings %v, want %v", tt.name, obs, tt.wantKelvin)
+		}
+		for _, k := range tt.wantKelvin {
+			if !got[k] {
+				t.Errorf("%s: missing the %vK reading", tt.name, k)
+			}
+		}
+
+		if asked := tt.fallback.calls > 0; asked != tt.wantFallback {
+			t.Errorf("%s: fallback group asked = %v, want %v", tt.name, asked, tt.wantFallback)
+		}
+	}
+}
+
+func TestParseProviderGroups(t *testing.T) {
+	tests := []struct {
+		spec, duplicates string
+		want             [][]string
+		wantErr          bool
+	}{
+		{"openweathermap,weatherunderground", duplicatesFirst, [][]string{{"openweathermap", "weatherunderground"}}, false},
+		{"openweathermap; weatherunderground", duplicatesStrict, [][]string{{"openweathermap"}, {"weatherunderground"}}, false},
+		{"openweathermap,openweathermap", duplicatesFirst, [][]string{{"openweathermap"}}, false},
+		{"openweathermap;openweathermap,weatherunderground", duplicatesFirst, [][]string{{"openweathermap"}, {"weatherunderground"}}, false},
+		{"weatherunderground;weatherunderground", duplicatesFirst, [][]string{{"weatherunderground"}}, false},
+		{"openweathermap,openweathermap", duplicatesStrict, nil, true},
+		{"openweathermap;weatherunderground,openweathermap", duplicatesStrict, nil, true},
+		{"openweathermap", "average", nil, true},
+		// Reserved for spreading calls across a provider's keys.
+		{"openweathermap", "merge", nil, true},
+		{"nws", duplicatesFirst, nil, true},
+		{" ; ", duplicatesFirst, nil, true},
+	}
+
+	for _, tt := range tests {
+		g, err := parseProviderGroups(tt.spec, tt.duplicates)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q (%s): err = %v, want error %v", tt.spec, tt.duplicates, err, tt.wantErr)
+			continue
+		}
+
+		var got [][]string
+		for _, group := range g {
+			var names []string
+			for _, p := range group {
+				names = append(names, p.name())
+			}
+			got = append(got, names)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%q (%s) = %v, want %v", tt.spec, tt.duplicates, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// logSampleRate is N in "log the details of one request in N". Errors are
+// printed directly and are never sampled.
+var logSampleRate int64 = 1
+
+var logRequests int64
+
+// sampleKey is the context key holding whether a request is in the sample.
+type sampleKey struct{}
+
+// sampleRequest returns ctx marked with whether its request falls in the
+// sample, so every detail line of one request is either logged or not.
+func sampleRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sampleKey{}, sampled(atomic.AddInt64(&logRequests, 1), logSampleRate))
+}
+
+// sampledHandler decides once per request whether h's detail lines are
+// logged.
+func sampledHandler(h http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) {
+		h(writer, req.WithContext(sampleRequest(req.Context())))
+	}
+}
+
+// logf prints a request detail line, such as a provider's response, if the
+// request falls in the sample. Lines outside a sampled request are always
+// printed.
+func logf(ctx context.Context, format string, args ...interface{}) {
+	if in, ok := ctx.Value(sampleKey{}).(bool); ok && !in {
+		return
+	}
+	fmt.Printf(format, args...)
+}
+
+// sampled reports whether the nth request is logged at the given rate.
+func sampled(n, rate int64) bool {
+	return rate <= 1 || n%rate == 0
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSampled(t *testing.T) {
+	tests := []struct {
+		rate int64
+		want int // requests logged out of 1000
+	}{
+		{0, 1000},
+		{1, 1000},
+		{2, 500},
+		{10, 100},
+		{1000, 1},
+	}
+
+	for _, tt := range tests {
+		got := 0
+		for n := int64(1); n <= 1000; n++ {
+			if sampled(n, tt.rate) {
+				got++
+			}
+		}
+		if got != tt.want {
+			t.Errorf("rate %d: sampled %d of 1000 requests, want %d", tt.rate, got, tt.want)
+		}
+	}
+}
+
+// captureStdout returns what f prints to standard output.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	w.Close()
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestLogfSamplesWholeRequests(t *testing.T) {
+	defer func(rate int64) { logSampleRate = rate }(logSampleRate)
+	logSampleRate = 4
+
+	out := captureStdout(t, func() {
+		for i := 0; i < 100; i++ {
+			ctx := sampleRequest(context.Background())
+			logf(ctx, "a\n")
+			logf(ctx, "b\n")
+			logf(ctx, "c\n")
+		}
+	})
+
+	// A sampled request logs every one of its lines.
+	if n := strings.Count(out, "a\nb\nc\n"); n != 25 {
+		t.Errorf("logged %d of 100 requests at rate 4, want 25", n)
+	}
+	if n := strings.Count(out, "\n"); n != 75 {
+		t.Errorf("logged %d lines, want 75", n)
+	}
+}
+
+func TestLogfWithoutRequest(t *testing.T) {
+	defer func(rate int64) { logSampleRate = rate }(logSampleRate)
+	logSampleRate = 1000
+
+	out := captureStdout(t, func() { logf(context.Background(), "startup\n") })
+	if out != "startup\n" {
+		t.Errorf("logged %q outside a request, want %q", out, "startup\n")
+	}
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,30 +30,179 @@ type weatherData struct {
 
 // Weather provider interface
 type weatherProvider interface {
-	temperature(city string) (float64, error)
+	name() string
+	attribution() string
+	coverage() []string
+	conditions(ctx context.Context, city string) (observation, error)
+}
+
+// observation is the current conditions reported by a provider. Optional
+// fields are nil when the provider did not report them.
+type observation struct {
+	provider     string
+	kelvin       float64
+	minKelvin    *float64 // lowest temperature currently observed in the area
+	maxKelvin    *float64 // highest temperature currently observed in the area
+	condition    string   // normalized condition, see conditions.go
+	rawCondition string   // condition as worded by the provider
+	humidity     *float64 // relative humidity in percent
+	windSpeed    *float64 // metres per second
+	visibility   *float64 // metres
+	cloudCover   *float64 // percent
+	ceiling      *float64 // feet above ground of the lowest broken or overcast layer, +Inf when there is none
+	observed     *time.Time
+	fetched      time.Time // when the provider returned it, which is earlier for cached readings
 }
 
 type openWeatherMap struct{}
-type weatherUnderground struct{}
+
+// weatherUnderground uses key when set, or the server's wuKey otherwise.
+type weatherUnderground struct {
+	key string
+}
 type multiWeatherProvider []weatherProvider
 
 var wuKey string
-var mw multiWeatherProvider
+var groups providerGroups
+
+// disagreementThreshold is the spread between the highest and lowest
+// provider readings, in Kelvin, above which a response carries a warning.
+var disagreementThreshold float64
+
+// freshnessHalfLife is the age at which a cached reading counts for half
+// as much as a fresh one in the average. Zero weights readings equally.
+var freshnessHalfLife time.Duration
+
+// staleAfter is the age beyond which observations are flagged as stale.
+var staleAfter time.Duration
+
+// providerDeadline is how long a request waits for providers. Providers
+// answering within lateGrace after it still update the cache. A zero
+// deadline waits for every provider.
+var providerDeadline, lateGrace time.Duration
+
+// defaultCity is queried by /weather/ when the request path has no city.
+var defaultCity string
 
 // Main entry point for the program.
 func main() {
+	flag.StringVar(&defaultCity, "default-city", "", "city to query when none is given in the request path")
+	geoURL := flag.String("geoip-url", "http://ip-api.com/json/", "IP geolocation service used by /weather/here, empty to disable")
+	flag.DurationVar(&cache.ttl, "cache-ttl", 0, "how long provider readings are cached, 0 to disable")
+	flag.Float64Var(&cache.jitter, "cache-jitter", 0.1, "fraction of the cache TTL to randomly add or remove per entry")
+	groupSpec := flag.String("provider-groups", "openweathermap,weatherunderground", "fallback groups of providers, separated by semicolons")
+	intervals := flag.String("min-interval", "", "minimum time between calls to each provider, e.g. openweathermap=1s")
+	flag.DurationVar(&throttleMaxWait, "max-throttle-wait", throttleMaxWait, "longest a call waits for its -min-interval slot before failing, 0 to wait indefinitely")
+	flag.StringVar(&geocodePolicy, "geocode-policy", pickFirst, "how to choose between places with the same name: first, population or unique")
+	dialTimeout := flag.Duration("dial-timeout", 5*time.Second, "time allowed to connect to an upstream API")
+	tlsTimeout := flag.Duration("tls-timeout", 5*time.Second, "time allowed for the TLS handshake with an upstream API")
+	continueTimeout := flag.Duration("expect-continue-timeout", time.Second, "time to wait for an upstream's 100-continue before sending a request body")
+	headerTimeout := flag.Duration("response-header-timeout", 10*time.Second, "time allowed for an upstream API to start responding")
+	requestTimeout := flag.Duration("request-timeout", 15*time.Second, "time allowed for a whole upstream request")
+	maxHeaderBytes := flag.Int("max-header-bytes", http.DefaultMaxHeaderBytes, "largest request header the server accepts")
+	flag.Float64Var(&disagreementThreshold, "disagreement-threshold", 5, "spread between provider readings in Kelvin that triggers a warning, 0 to disable")
+	flag.IntVar(&maxTilePoints, "max-tile-points", maxTilePoints, "most grid points a /tile request may sample")
+	flag.IntVar(&coordinatePrecision, "coordinate-precision", coordinatePrecision, "decimal places /tile coordinates are rounded to when caching their readings")
+	flag.Int64Var(&logSampleRate, "log-sample", 1, "log the details of one request in N, errors are always logged")
+	offsets := flag.String("offset", "", "calibration offset in Kelvin added to each provider's readings, e.g. openweathermap=1")
+	units := flag.String("units", defaultUnits, "temperature units used when a request does not choose: kelvin, celsius or fahrenheit")
+	flag.DurationVar(&staleAfter, "stale-after", 2*time.Hour, "observation age beyond which a response carries data_age_warning, 0 to disable")
+	duplicates := flag.String("duplicate-providers", duplicatesFirst, "how to handle a provider listed more than once: strict or first")
+	flag.DurationVar(&freshnessHalfLife, "freshness-half-life", 0, "age at which a cached reading counts half as much as a fresh one, 0 to weight equally")
+	replicaSpec := flag.String("replicas", "", "base URLs to race for each provider, e.g. openweathermap=http://a|http://b")
+	flag.DurationVar(&previous.window, "delta-window", previous.window, "how long a client's last temperature is kept for delta_since_last, 0 to disable")
+	flag.DurationVar(&providerDeadline, "provider-deadline", 0, "how long a request waits for providers, 0 to wait for all")
+	flag.DurationVar(&lateGrace, "late-grace", 30*time.Second, "how long after the deadline a late provider answer is still cached")
+	flag.Float64Var(&scoring.idealMinC, "score-ideal-min", scoring.idealMinC, "lowest ideal temperature in Celsius for the weather score")
+	flag.Float64Var(&scoring.idealMaxC, "score-ideal-max", scoring.idealMaxC, "highest ideal temperature in Celsius for the weather score")
+	flag.Float64Var(&scoring.tempWeight, "score-weight-temp", scoring.tempWeight, "weight of temperature in the weather score")
+	flag.Float64Var(&scoring.precipWeight, "score-weight-precip", scoring.precipWeight, "weight of precipitation in the weather score")
+	flag.Float64Var(&scoring.windWeight, "score-weight-wind", scoring.windWeight, "weight of wind in the weather score")
+	flag.Float64Var(&scoring.cloudWeight, "score-weight-cloud", scoring.cloudWeight, "weight of cloud cover in the weather score")
+	flag.Parse()
+
+	client = newClient(*dialTimeout, *tlsTimeout, *continueTimeout, *headerTimeout, *requestTimeout)
+
+	if *geoURL != "" {
+		geo = ipAPI{url: *geoURL}
+	}
+
 	getAPIKeys()
 
-	mw = multiWeatherProvider{
-		openWeatherMap{},
-		weatherUnderground{},
+	var err error
+	if defaultUnits, err = parseUnits(*units); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if cache.jitter < 0 || cache.jitter >= 1 {
+		fmt.Printf("Cache jitter must be at least 0 and less than 1, got %v\n", cache.jitter)
+		os.Exit(1)
+	}
+
+	if coordinatePrecision < 0 || coordinatePrecision > 6 {
+		fmt.Printf("Coordinate precision must be between 0 and 6 decimal places, got %d\n", coordinatePrecision)
+		os.Exit(1)
+	}
+
+	switch geocodePolicy {
+	case pickFirst, pickPopulation, pickUnique:
+	default:
+		fmt.Printf("Unknown geocoding policy %q\n", geocodePolicy)
+		os.Exit(1)
+	}
+
+	if err := offsetProviders(*offsets); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := setReplicas(*replicaSpec); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := throttleProviders(*intervals); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if groups, err = parseProviderGroups(*groupSpec, *duplicates); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if cache.ttl > 0 {
+		go cache.sweepEvery(cache.ttl)
+	}
+	if previous.window > 0 {
+		go previous.sweepEvery(previous.window)
 	}
 
 	http.HandleFunc("/", hello)
-	http.HandleFunc("/weather/", weather)
+	http.HandleFunc("/weather/", sampledHandler(weather))
+	http.HandleFunc("/series", sampledHandler(series))
+	http.HandleFunc("/astro", sampledHandler(astro))
+	http.HandleFunc("/tile", sampledHandler(tile))
+	http.HandleFunc("/config", configHandler)
+	http.HandleFunc("/errors", errorsHandler)
+	http.HandleFunc("/providers", providersHandler)
+	http.HandleFunc("/stats", statsHandler)
+	http.HandleFunc("/stats/reset", statsReset)
+
+	server := newServer(":8000", *maxHeaderBytes)
 
 	fmt.Println("Listening on :8000")
-	http.ListenAndServe(":8000", nil)
+	server.ListenAndServe()
+}
+
+// newServer returns the server for the registered handlers, which rejects
+// requests whose headers are larger than maxHeaderBytes.
+func newServer(addr string, maxHeaderBytes int) *http.Server {
+	return &http.Server{
+		Addr:           addr,
+		MaxHeaderBytes: maxHeaderBytes,
+	}
 }
 
 // Say hello!
@@ -71,108 +229,634 @@ func getAPIKeys() {
 func weather(writer http.ResponseWriter, req *http.Request) {
 	begin := time.Now()
 	city := strings.SplitN(req.URL.Path, "/", 3)[2]
+	if city == "" {
+		if defaultCity == "" {
+			writeError(writer, errMissingCity, "a city must be given")
+			return
+		}
+		fmt.Printf("No city given, using default city %s\n", defaultCity)
+		city = defaultCity
+	}
+
+	if city == "here" {
+		var err error
+		if city, err = locate(req); err != nil {
+			writeError(writer, errGeolocation, err.Error())
+			return
+		}
+	}
+
+	if key := req.Header.Get("Idempotency-Key"); key != "" {
+		writer.Header().Set("Idempotency-Key", key)
+	}
+
+	units, err := requestUnits(req)
+	if err != nil {
+		writeError(writer, errInvalidParameter, err.Error())
+		return
+	}
 
-	temp, err := mw.temperature(city)
+	sigfigs, err := requestSigFigs(req)
 	if err != nil {
-		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		writeError(writer, errInvalidParameter, err.Error())
 		return
 	}
 
+	// display converts a Kelvin temperature to the response's units and
+	// precision. temp_k is deliberately left unrounded.
+	display := func(k float64) float64 {
+		return roundToSigFigs(fromKelvin(k, units), sigfigs)
+	}
+
+	g := groups
+	if header := req.Header.Get("X-Provider-Key"); header != "" {
+		name, key, err := parseClientKey(header)
+		if err != nil {
+			writeError(writer, errInvalidParameter, err.Error())
+			return
+		}
+		g = g.withClientKey(name, key)
+	}
+
+	obs, err := g.readings(req.Context(), city)
+	if err != nil {
+		recordRequest(time.Since(begin), true)
+		writeError(writer, errUpstream, err.Error())
+		return
+	}
+
+	kelvin := freshnessMean(obs, freshnessHalfLife, time.Now())
+	tempF := fromKelvin(kelvin, unitsFahrenheit)
+
+	fp := fingerprint(city, units, sigfigs, begin, fingerprintWindow())
+	writer.Header().Set("ETag", `W/"`+fp+`"`)
+	// Without ?units= the units come from Accept-Language. Responses using
+	// a client's own key or carrying its delta_since_last are its alone.
+	writer.Header().Set("Vary", "Accept-Language")
+	private := req.Header.Get("X-Provider-Key") != "" || previous.window > 0 && clientToken(req) != ""
+	switch {
+	case cache.ttl > 0 && private:
+		writer.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(cache.ttl.Seconds())))
+	case cache.ttl > 0:
+		writer.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(cache.ttl.Seconds())))
+	case private:
+		writer.Header().Set("Cache-Control", "private")
+	}
+
+	// HEAD only needs the headers, so skip building the body.
+	if req.Method == http.MethodHead {
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		writer.WriteHeader(http.StatusNoContent)
+		recordRequest(time.Since(begin), false)
+		return
+	}
+
+	response := map[string]interface{}{
+		"city":        city,
+		"temp":        display(kelvin),
+		"temp_k":      kelvin,
+		"units":       units,
+		"confidence":  confidence(obs),
+		"fingerprint": fp,
+		"as_of":       asOf(obs, begin),
+	}
+
+	if prev, ok := previous.swap(clientToken(req), city, kelvin); ok {
+		response["delta_since_last"] = roundConversion(fromKelvin(kelvin, units) - fromKelvin(prev, units))
+	}
+
+	if w, ok := disagreement(obs, disagreementThreshold); ok {
+		response["warning"] = w
+	}
+
+	if oldest, ok := stale(obs, staleAfter); ok {
+		response["data_age_warning"] = true
+		response["oldest_observation"] = oldest
+	}
+
+	if k, ok := mean(obs, func(o observation) *float64 { return o.minKelvin }); ok {
+		response["temp_min"] = display(k)
+	}
+	if k, ok := mean(obs, func(o observation) *float64 { return o.maxKelvin }); ok {
+		response["temp_max"] = display(k)
+	}
+
+	humidity, hasHumidity := mean(obs, func(o observation) *float64 { return o.humidity })
+	wind, hasWind := mean(obs, func(o observation) *float64 { return o.windSpeed })
+	if hasHumidity {
+		response["humidity"] = humidity
+		response["wet_bulb_c"] = wetBulb(fromKelvin(kelvin, unitsCelsius), humidity)
+	}
+	if hasWind {
+		response["wind_speed"] = wind
+	}
+	visibility, hasVisibility := mean(obs, func(o observation) *float64 { return o.visibility })
+	// The lowest ceiling reported is the safe one to fly by.
+	ceiling, hasCeiling := minimum(obs, func(o observation) *float64 { return o.ceiling })
+	if hasVisibility && hasCeiling {
+		response["flight_category"] = flightCategory(visibility, ceiling)
+	}
+	score := scoreInputs{tempC: fromKelvin(kelvin, unitsCelsius)}
+	if hasWind {
+		score.windSpeed = &wind
+	}
+	if cloud, ok := mean(obs, func(o observation) *float64 { return o.cloudCover }); ok {
+		score.cloudCover = &cloud
+	}
+	if condition, ok := prevailingCondition(obs); ok {
+		score.precipChance = precipitationChance(condition)
+
+		raw := map[string]string{}
+		for _, o := range obs {
+			if o.rawCondition != "" {
+				raw[o.provider] = o.rawCondition
+			}
+		}
+		response["condition"] = condition
+		response["condition_raw"] = raw
+	}
+	response["score"], response["score_components"] = weatherScore(score, scoring)
+
+	if index, formula, ok := comfort(tempF, humidity, hasHumidity, wind*2.23694, hasWind); ok {
+		response["comfort"] = display(fahrenheitToKelvin(index))
+		response["comfort_formula"] = formula
+	}
+
+	response["attribution"] = attributions(obs)
+	response["took"] = formatTook(req, time.Since(begin))
+
 	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-	json.NewEncoder(writer).Encode(map[string]interface{}{
-		"city": city,
-		"temp": temp,
-		"took": time.Since(begin).String(),
-	})
+	json.NewEncoder(writer).Encode(response)
+
+	recordRequest(time.Since(begin), false)
+}
+
+// fingerprint identifies a weather request for city in units, rounded to
+// sigfigs significant figures, made at the given time. Requests for the same
+// city, units and precision in the same window share a fingerprint, so a
+// client retrying can tell it got the same answer.
+func fingerprint(city, units string, sigfigs int, at time.Time, window time.Duration) string {
+	city = strings.ToLower(strings.TrimSpace(city))
+	bucket := at.UnixNano() / int64(window)
+	sum := sha256.Sum256([]byte(city + "|" + units + "|" + strconv.Itoa(sigfigs) + "|" + strconv.FormatInt(bucket, 10)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// fingerprintWindow returns the window fingerprints are bucketed by: the
+// cache TTL, or a minute when caching is disabled.
+func fingerprintWindow() time.Duration {
+	if cache.ttl > 0 {
+		return cache.ttl
+	}
+	return time.Minute
+}
+
+// formatTook formats a request duration for the "took" field. Durations use
+// Go's format unless the request asks for ?duration_format=iso8601.
+func formatTook(req *http.Request, d time.Duration) string {
+	if req.URL.Query().Get("duration_format") == "iso8601" {
+		return iso8601Duration(d)
+	}
+	return d.String()
+}
 
+// iso8601Duration formats d as an ISO 8601 duration such as PT1M0.5S.
+func iso8601Duration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	out := sign + "PT"
+	if h := d / time.Hour; h > 0 {
+		out += fmt.Sprintf("%dH", h)
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		out += fmt.Sprintf("%dM", m)
+		d -= m * time.Minute
+	}
+	if d > 0 {
+		out += strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+	}
+	return out
 }
 
 // query takes the name of a city as a string and queries the OpenWeatherMap API
 // for weather data. This function either returns a weatherData struct of the
 // returned data, or an error object.
-func (w openWeatherMap) temperature(city string) (float64, error) {
-	resp, err := http.Get("http://api.openweathermap.org/data/2.5/weather?q=" + city)
+func (w openWeatherMap) conditions(ctx context.Context, city string) (observation, error) {
+	resp, err := hedgedDo(ctx, w.name(), upstreamRequest{path: "/data/2.5/weather?q=" + city})
 	if err != nil {
-		return 0, err
+		return observation{}, err
 	}
 
 	defer resp.Body.Close()
 
+	if err := checkRetryAfter(w.name(), resp); err != nil {
+		return observation{}, err
+	}
+
 	var d struct {
 		Main struct {
-			Kelvin float64 `json:"temp"`
+			Kelvin    float64  `json:"temp"`
+			MinKelvin *float64 `json:"temp_min"`
+			MaxKelvin *float64 `json:"temp_max"`
+			Humidity  *float64 `json:"humidity"`
 		} `json:"main"`
+		Wind struct {
+			Speed *float64 `json:"speed"`
+		} `json:"wind"`
+		Weather []struct {
+			Main        string `json:"main"`
+			Description string `json:"description"`
+		} `json:"weather"`
+		Clouds struct {
+			All *float64 `json:"all"`
+		} `json:"clouds"`
+		Visibility *float64 `json:"visibility"`
+		Time       int64    `json:"dt"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
-		return 0, err
+		return observation{}, err
 	}
 
-	fmt.Printf("OpenWeatherMap responded with %.2fK for %s\n", d.Main.Kelvin, city)
+	logf(ctx, "OpenWeatherMap responded with %.2fK for %s\n", d.Main.Kelvin, city)
+
+	o := observation{
+		kelvin:     d.Main.Kelvin,
+		minKelvin:  d.Main.MinKelvin,
+		maxKelvin:  d.Main.MaxKelvin,
+		humidity:   d.Main.Humidity,
+		windSpeed:  d.Wind.Speed,
+		visibility: d.Visibility,
+		cloudCover: d.Clouds.All,
+		ceiling:    ceilingFromCover(d.Clouds.All),
+	}
+	if d.Time > 0 {
+		t := time.Unix(d.Time, 0)
+		o.observed = &t
+	}
+	if len(d.Weather) > 0 {
+		o.rawCondition = d.Weather[0].Description
+		o.condition = normalizeCondition(owmConditions, d.Weather[0].Description, d.Weather[0].Main)
+	}
 
-	return d.Main.Kelvin, nil
+	return o, nil
 }
 
-func (w weatherUnderground) temperature(city string) (float64, error) {
-	if wuKey == "" {
-		return 0, errors.New("Weather Underground API key must be set")
+func (w openWeatherMap) name() string { return "openweathermap" }
+
+func (w weatherUnderground) name() string { return "weatherunderground" }
+
+func (w openWeatherMap) attribution() string { return "Weather data provided by OpenWeather" }
+
+func (w weatherUnderground) attribution() string { return "Data provided by Weather Underground" }
+
+// Both providers are global.
+func (w openWeatherMap) coverage() []string { return nil }
+
+func (w weatherUnderground) coverage() []string { return nil }
+
+func (w weatherUnderground) conditions(ctx context.Context, city string) (observation, error) {
+	key := w.key
+	if key == "" {
+		key = wuKey
+	}
+	if key == "" {
+		return observation{}, errors.New("Weather Underground API key must be set")
 	}
 
-	resp, err := http.Get("http://api.wunderground.com/api/" + wuKey + "/conditions/q/" + city + ".json")
+	resp, err := hedgedDo(ctx, w.name(), upstreamRequest{path: "/api/" + key + "/conditions/q/" + city + ".json"})
 	if err != nil {
-		return 0, err
+		// Errors carry the URL, which contains the key.
+		return observation{}, errors.New(strings.Replace(err.Error(), key, "***", -1))
 	}
 
 	defer resp.Body.Close()
 
+	if err := checkRetryAfter(w.name(), resp); err != nil {
+		return observation{}, err
+	}
+
 	var d struct {
 		Observation struct {
-			Celcius float64 `json:"temp_c"`
+			Celcius  float64  `json:"temp_c"`
+			Humidity string   `json:"relative_humidity"`
+			WindKph  *float64 `json:"wind_kph"`
+			Weather  string   `json:"weather"`
+			Epoch    string   `json:"observation_epoch"`
+			VisKm    string   `json:"visibility_km"`
 		} `json:"current_observation"`
 	}
 
 	if err = json.NewDecoder(resp.Body).Decode(&d); err != nil {
-		return 0, err
+		return observation{}, err
 	}
 
-	kelvin := d.Observation.Celcius + 273.15
-	fmt.Printf("Weather Underground responded with %.2fK for %s\n", kelvin, city)
+	kelvin := celsiusToKelvin(d.Observation.Celcius)
+	logf(ctx, "Weather Underground responded with %.2fK for %s\n", kelvin, city)
+
+	o := observation{kelvin: kelvin}
+	if km, err := strconv.ParseFloat(d.Observation.VisKm, 64); err == nil {
+		m := km * 1000
+		o.visibility = &m
+	}
+	if epoch, err := strconv.ParseInt(d.Observation.Epoch, 10, 64); err == nil {
+		t := time.Unix(epoch, 0)
+		o.observed = &t
+	}
+	if d.Observation.Weather != "" {
+		o.rawCondition = d.Observation.Weather
+		o.condition = normalizeCondition(wuConditions, d.Observation.Weather)
+	}
+	if h, err := strconv.ParseFloat(strings.TrimSuffix(d.Observation.Humidity, "%"), 64); err == nil {
+		o.humidity = &h
+	}
+	if d.Observation.WindKph != nil {
+		ms := *d.Observation.WindKph / 3.6
+		o.windSpeed = &ms
+	}
 
-	return kelvin, nil
+	return o, nil
 }
 
-func (w multiWeatherProvider) temperature(city string) (float64, error) {
-	// Make one channel for temperatures and one channel for errors.
+// readings queries every provider concurrently and returns the observation
+// from each provider that succeeded. An error is only returned when no
+// provider produced a reading.
+func (w multiWeatherProvider) readings(ctx context.Context, city string) ([]observation, error) {
+	if w = w.covering(ctx, city); len(w) == 0 {
+		return nil, fmt.Errorf("no weather provider covers %s", city)
+	}
+
+	// Make one channel for observations and one channel for errors.
 	// Each provider will push a value into only one channel.
-	temps := make(chan float64, len(w))
+	results := make(chan observation, len(w))
 	errs := make(chan error, len(w))
 
 	// For each provider, spawn a goroutine with an anonymous function.
-	// That function will invoke the temperature method and forward the response.
+	// That function will invoke the conditions method and forward the response.
+	// The goroutines outlive a request that stops waiting at the deadline, so
+	// late answers still reach the cache, and their calls are detached from
+	// the request's cancellation. Only a call still waiting for its throttle
+	// slot gives up when the request ends.
+	detached := withRequestDone(context.WithoutCancel(ctx), ctx.Done())
+	deadlined := providerDeadline > 0
+	cacheBefore := time.Now().Add(providerDeadline + lateGrace)
 	for _, provider := range w {
 		go func(p weatherProvider) {
-			k, err := p.temperature(city)
+			shared := !isClientKeyed(p)
+			if o, ok := cache.get(p.name(), city); ok && shared {
+				results <- o
+				return
+			}
+			if until, ok := cooldowns.active(p.name()); ok && shared {
+				errs <- fmt.Errorf("skipping %s until %s", p.name(), until.Format(time.RFC3339))
+				return
+			}
+			o, err := p.conditions(detached, city)
 			if err != nil {
+				if ra, ok := err.(*retryAfterError); ok && shared {
+					cooldowns.set(p.name(), ra.until)
+				}
 				errs <- err
 				return
 			}
-			temps <- k
+			// A throttled provider answers from the cache when another
+			// request fetched the city while this one waited. That reading
+			// is already stamped, transformed and cached.
+			if !o.fetched.IsZero() {
+				results <- o
+				return
+			}
+			o.provider = p.name()
+			o.fetched = time.Now()
+			o = transform(p.name(), o)
+			if shared {
+				lastSuccess.record(p.name())
+				if !deadlined || !time.Now().After(cacheBefore) {
+					cache.set(p.name(), city, o)
+				}
+			}
+			results <- o
 		}(provider)
 	}
 
-	sum := 0.0
+	var obs []observation
+	var lastErr error
 
-	// Collect a temperature or error from each provider
+	var deadline <-chan time.Time
+	if deadlined {
+		deadline = time.After(providerDeadline)
+	}
+
+	// Collect an observation or error from each provider, or as many as
+	// answer before the deadline
+collect:
 	for i := 0; i < len(w); i++ {
 		select {
-		case temp := <-temps:
-			f := (temp * 1.8) - 459.67
-			fmt.Printf("%.2fK converts to %.2fF\n", temp, f)
-			sum += f
+		case o := <-results:
+			obs = append(obs, o)
 		case err := <-errs:
-			return 0, err
+			fmt.Println(err)
+			atomic.AddInt64(&stats.providerErrors, 1)
+			lastErr = err
+		case <-deadline:
+			lastErr = fmt.Errorf("%d providers missed the %s deadline for %s", len(w)-i, providerDeadline, city)
+			fmt.Println(lastErr)
+			break collect
+		}
+	}
+
+	if len(obs) == 0 {
+		if lastErr == nil {
+			lastErr = errors.New("no weather providers configured")
+		}
+		return nil, lastErr
+	}
+
+	return obs, nil
+}
+
+// mean averages the field selected by field across the observations that
+// report it. It returns false when none do.
+func mean(obs []observation, field func(observation) *float64) (float64, bool) {
+	sum, n := 0.0, 0
+	for _, o := range obs {
+		if v := field(o); v != nil {
+			sum += *v
+			n++
 		}
 	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// minimum returns the lowest value of the field selected by field across the
+// observations that report it. It returns false when none do.
+func minimum(obs []observation, field func(observation) *float64) (float64, bool) {
+	low, ok := 0.0, false
+	for _, o := range obs {
+		if v := field(o); v != nil && (!ok || *v < low) {
+			low, ok = *v, true
+		}
+	}
+	return low, ok
+}
+
+// attributions returns the attribution text of each provider that
+// contributed an observation, in the order they answered.
+func attributions(obs []observation) []string {
+	var list []string
+	seen := map[string]bool{}
+	for _, o := range obs {
+		if p, ok := providers[o.provider]; ok && !seen[o.provider] {
+			seen[o.provider] = true
+			list = append(list, p.attribution())
+		}
+	}
+	return list
+}
+
+// disagreement returns a warning when the highest and lowest readings are
+// more than threshold Kelvin apart. The warning names the providers that
+// reported the extremes.
+func disagreement(obs []observation, threshold float64) (map[string]interface{}, bool) {
+	if threshold <= 0 || len(obs) < 2 {
+		return nil, false
+	}
+
+	low, high := obs[0], obs[0]
+	for _, o := range obs[1:] {
+		if o.kelvin < low.kelvin {
+			low = o
+		}
+		if o.kelvin > high.kelvin {
+			high = o
+		}
+	}
+
+	spread := high.kelvin - low.kelvin
+	if spread <= threshold {
+		return nil, false
+	}
+
+	return map[string]interface{}{
+		"message":   fmt.Sprintf("providers disagree by %.2fK", spread),
+		"spread_k":  spread,
+		"providers": []string{high.provider, low.provider},
+	}, true
+}
+
+// freshnessMean averages the Kelvin readings, weighting each by
+// 0.5^(age/halfLife) where age is how much longer ago the reading was
+// fetched than the freshest one, so readings served from the cache count for
+// less the older they are. Measuring from the freshest reading, which always
+// weighs 1, keeps the weights from all underflowing to zero when every
+// reading is many half-lives old. A zero halfLife weights every reading
+// equally.
+func freshnessMean(obs []observation, halfLife time.Duration, now time.Time) float64 {
+	if halfLife <= 0 {
+		k, _ := mean(obs, func(o observation) *float64 { return &o.kelvin })
+		return k
+	}
+
+	// Readings fetched after now count as fetched now.
+	var freshest time.Time
+	for _, o := range obs {
+		if o.fetched.After(freshest) {
+			freshest = o.fetched
+		}
+	}
+	if freshest.After(now) {
+		freshest = now
+	}
+
+	sum, weights := 0.0, 0.0
+	for _, o := range obs {
+		age := freshest.Sub(o.fetched)
+		if age < 0 {
+			age = 0
+		}
+		w := math.Pow(0.5, float64(age)/float64(halfLife))
+		sum += w * o.kelvin
+		weights += w
+	}
+	return sum / weights
+}
+
+// asOf returns the time the aggregate best reflects: the median of the
+// providers' observation times, or fallback when none reported one. With an
+// even number of times the midpoint of the middle two is used.
+func asOf(obs []observation, fallback time.Time) time.Time {
+	var times []time.Time
+	for _, o := range obs {
+		if o.observed != nil {
+			times = append(times, *o.observed)
+		}
+	}
+	if len(times) == 0 {
+		return fallback
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	mid := len(times) / 2
+	if len(times)%2 == 1 {
+		return times[mid]
+	}
+	return times[mid-1].Add(times[mid].Sub(times[mid-1]) / 2)
+}
+
+// stale reports whether every observation is older than maxAge, returning
+// the oldest observation time. Observations without a time are never
+// considered stale.
+func stale(obs []observation, maxAge time.Duration) (time.Time, bool) {
+	if maxAge <= 0 || len(obs) == 0 {
+		return time.Time{}, false
+	}
+
+	var oldest time.Time
+	for _, o := range obs {
+		if o.observed == nil || time.Since(*o.observed) <= maxAge {
+			return time.Time{}, false
+		}
+		if oldest.IsZero() || o.observed.Before(oldest) {
+			oldest = *o.observed
+		}
+	}
+	return oldest, true
+}
+
+// confidence returns a score between 0 and 1 describing how much the averaged
+// temperature can be trusted, based on how many providers answered and how
+// closely their readings agree:
+//
+//	confidence = n/(n+1) * 1/(1 + σ/1K)
+//
+// where n is the number of readings and σ is their standard deviation in
+// Kelvin. A single reading scores at most 0.5; many closely agreeing readings
+// approach 1.
+func confidence(obs []observation) float64 {
+	n := float64(len(obs))
+	if n == 0 {
+		return 0
+	}
+
+	avg, _ := mean(obs, func(o observation) *float64 { return &o.kelvin })
+
+	variance := 0.0
+	for _, o := range obs {
+		variance += (o.kelvin - avg) * (o.kelvin - avg)
+	}
+	stddev := math.Sqrt(variance / n)
 
-	return sum / float64(len(w)), nil
+	return n / (n + 1) * 1 / (1 + stddev)
 }
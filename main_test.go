@@ -0,0 +1,710 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeProvider answers with obs, or err when set, after delay.
+type fakeProvider struct {
+	id     string
+	obs    observation
+	err    error
+	delay  time.Duration
+	region []string
+
+	calls int64
+
+	mu     sync.Mutex
+	cities []string    // every city asked for, in order
+	times  []time.Time // when each call was made
+}
+
+func (f *fakeProvider) name() string        { return f.id }
+func (f *fakeProvider) attribution() string { return "Data provided by " + f.id }
+func (f *fakeProvider) coverage() []string  { return f.region }
+
+func (f *fakeProvider) conditions(ctx context.Context, city string) (observation, error) {
+	atomic.AddInt64(&f.calls, 1)
+	f.mu.Lock()
+	f.cities = append(f.cities, city)
+	f.times = append(f.times, time.Now())
+	f.mu.Unlock()
+	time.Sleep(f.delay)
+	if f.err != nil {
+		return observation{}, f.err
+	}
+	return f.obs, nil
+}
+
+// reading returns a fake provider that always reports kelvin.
+func reading(id string, kelvin float64) *fakeProvider {
+	return &fakeProvider{id: id, obs: observation{kelvin: kelvin}}
+}
+
+// failing returns a fake provider that always fails.
+func failing(id string) *fakeProvider {
+	return &fakeProvider{id: id, err: errors.New(id + " is down")}
+}
+
+// useGroups makes the weather handler query g until the test ends.
+func useGroups(t *testing.T, g ...multiWeatherProvider) {
+	t.Helper()
+	saved := groups
+	groups = g
+	t.Cleanup(func() { groups = saved })
+}
+
+// useCacheTTL caches provider readings for ttl, in an empty cache, until the
+// test ends.
+func useCacheTTL(t *testing.T, ttl time.Duration) {
+	t.Helper()
+	saved := cache
+	cache = &readingCache{ttl: ttl, entries: map[string]cacheEntry{}}
+	t.Cleanup(func() { cache = saved })
+}
+
+// getWeather serves a GET of path by the weather handler and decodes the
+// JSON response.
+func getWeather(t *testing.T, path string, header http.Header) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	weather(rec, req)
+
+	var body map[string]interface{}
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decoding %q: %v", path, rec.Body.String(), err)
+		}
+	}
+	return rec, body
+}
+
+func TestConfidence(t *testing.T) {
+	obs := func(kelvins ...float64) []observation {
+		var list []observation
+		for _, k := range kelvins {
+			list = append(list, observation{kelvin: k})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name string
+		obs  []observation
+		want float64
+	}{
+		{"none", nil, 0},
+		{"single", obs(290), 0.5},
+		{"two agreeing", obs(290, 290), 2.0 / 3},
+		{"four agreeing", obs(290, 290, 290, 290), 0.8},
+		{"two a Kelvin apart", obs(289.5, 290.5), 2.0 / 3 / 1.5},
+		{"two far apart", obs(280, 300), 2.0 / 3 / 11},
+	}
+
+	for _, tt := range tests {
+		if got := confidence(tt.obs); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: confidence = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	many := confidence(obs(290, 290.1, 289.9, 290, 290.05, 289.95))
+	if single := confidence(obs(290)); many <= single || many < 0.8 {
+		t.Errorf("six agreeing providers score %v, want above 0.8 and the single provider's %v", many, single)
+	}
+}
+
+func TestReadingsPartialSuccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		group   multiWeatherProvider
+		wantN   int
+		wantErr bool
+	}{
+		{"all answer", multiWeatherProvider{reading("a", 280), reading("b", 290)}, 2, false},
+		{"one fails", multiWeatherProvider{reading("a", 280), failing("b"), reading("c", 290)}, 2, false},
+		{"all fail", multiWeatherProvider{failing("a"), failing("b")}, 0, true},
+		{"none configured", nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		obs, err := tt.group.readings(context.Background(), "Paris")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if len(obs) != tt.wantN {
+			t.Errorf("%s: got %d readings, want %d", tt.name, len(obs), tt.wantN)
+		}
+	}
+}
+
+func TestReadingsCachesLateAnswers(t *testing.T) {
+	defer func(d, g time.Duration) { providerDeadline, lateGrace = d, g }(providerDeadline, lateGrace)
+	providerDeadline = 20 * time.Millisecond
+
+	tests := []struct {
+		name       string
+		grace      time.Duration
+		wantCached bool
+	}{
+		{"within the grace", time.Second, true},
+		{"after the grace", 0, false},
+	}
+
+	for _, tt := range tests {
+		useCacheTTL(t, time.Hour)
+		lateGrace = tt.grace
+		fast, slow := reading("fast", 280), reading("slow", 290)
+		slow.delay = 100 * time.Millisecond
+
+		obs, err := multiWeatherProvider{fast, slow}.readings(context.Background(), "Oslo")
+		if err != nil || len(obs) != 1 || obs[0].provider != "fast" {
+			t.Fatalf("%s: readings = %v, %v, want only the fast provider's", tt.name, obs, err)
+		}
+		if _, ok := cache.get("slow", "Oslo"); ok {
+			t.Fatalf("%s: the slow answer was cached before it arrived", tt.name)
+		}
+
+		// Give the slow provider time to answer after the request returned.
+		time.Sleep(2 * slow.delay)
+		o, ok := cache.get("slow", "Oslo")
+		if ok != tt.wantCached || ok && o.kelvin != 290 {
+			t.Errorf("%s: cached late answer = %v, %v, want cached %v", tt.name, o.kelvin, ok, tt.wantCached)
+		}
+	}
+}
+
+func TestWeatherAveragesProvidersThatAnswered(t *testing.T) {
+	useGroups(t, multiWeatherProvider{reading("a", 280), failing("b"), reading("c", 290)})
+
+	rec, body := getWeather(t, "/weather/Paris?units=k", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	// The failed provider must not drag the average towards zero.
+	if got := body["temp_k"]; got != 285.0 {
+		t.Errorf("temp_k = %v, want 285", got)
+	}
+	if got := body["confidence"].(float64); math.Abs(got-2.0/3/6) > 1e-9 {
+		t.Errorf("confidence = %v, want %v", got, 2.0/3/6)
+	}
+}
+
+func TestWeatherDefaultCity(t *testing.T) {
+	defer func(city string) { defaultCity = city }(defaultCity)
+
+	tests := []struct {
+		defaultCity string
+		path        string
+		wantStatus  int
+		wantCity    string
+	}{
+		{"Kiosk Town", "/weather/", http.StatusOK, "Kiosk Town"},
+		{"Kiosk Town", "/weather/Paris", http.StatusOK, "Paris"},
+		{"", "/weather/", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		p := reading("fake", 290)
+		useGroups(t, multiWeatherProvider{p})
+		defaultCity = tt.defaultCity
+
+		rec := httptest.NewRecorder()
+		out := captureStdout(t, func() { weather(rec, httptest.NewRequest(http.MethodGet, tt.path, nil)) })
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s with default %q: status = %d, want %d", tt.path, tt.defaultCity, rec.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantCity == "" {
+			if len(p.cities) != 0 {
+				t.Errorf("%s without a default: queried %v, want nothing", tt.path, p.cities)
+			}
+			continue
+		}
+		if len(p.cities) != 1 || p.cities[0] != tt.wantCity {
+			t.Errorf("%s with default %q: queried %v, want [%s]", tt.path, tt.defaultCity, p.cities, tt.wantCity)
+		}
+		usedDefault := strings.Contains(out, "using default city "+tt.defaultCity)
+		if usedDefault != (tt.wantCity == tt.defaultCity) {
+			t.Errorf("%s with default %q: logged %q", tt.path, tt.defaultCity, out)
+		}
+	}
+}
+
+func TestISO8601Duration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{234 * time.Millisecond, "PT0.234S"},
+		{1500 * time.Microsecond, "PT0.0015S"},
+		{time.Minute + 500*time.Millisecond, "PT1M0.5S"},
+		{2 * time.Hour, "PT2H"},
+		{time.Hour + 2*time.Minute + 3*time.Second, "PT1H2M3S"},
+		{-90 * time.Second, "-PT1M30S"},
+	}
+
+	for _, tt := range tests {
+		if got := iso8601Duration(tt.d); got != tt.want {
+			t.Errorf("iso8601Duration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestFormatTook(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "234ms"},
+		{"?duration_format=go", "234ms"},
+		{"?duration_format=iso8601", "PT0.234S"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/weather/Paris"+tt.query, nil)
+		if got := formatTook(req, 234*time.Millisecond); got != tt.want {
+			t.Errorf("formatTook(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestWeatherAlwaysIncludesKelvin(t *testing.T) {
+	useGroups(t, multiWeatherProvider{reading("a", 293.15), reading("b", 294.15)})
+
+	for _, units := range []string{"c", "f", "k"} {
+		_, body := getWeather(t, "/weather/Paris?sigfigs=2&units="+units, nil)
+		if got, ok := body["temp_k"]; !ok || got != 293.65 {
+			t.Errorf("units %s: temp_k = %v, want 293.65 unrounded", units, got)
+		}
+	}
+
+	_, body := getWeather(t, "/weather/Paris?units=c", nil)
+	if body["temp"] != 20.5 || body["units"] != unitsCelsius {
+		t.Errorf("temp = %v %v, want 20.5 celsius", body["temp"], body["units"])
+	}
+}
+
+func TestNewServerMaxHeaderBytes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newServer(ln.Addr().String(), 1024)
+	go server.Serve(ln)
+	defer server.Close()
+
+	tests := []struct {
+		headerBytes int
+		want        int
+	}{
+		{100, http.StatusNotFound},
+		// net/http allows 4KB of slack above the limit.
+		{16 << 10, http.StatusRequestHeaderFieldsTooLarge},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+"/missing", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Padding", strings.Repeat("x", tt.headerBytes))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%d byte header: status = %d, want %d", tt.headerBytes, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+// useReplicas points the provider at the given base URLs until the test
+// ends.
+func useReplicas(t *testing.T, provider string, urls ...string) {
+	t.Helper()
+	saved, ok := replicas[provider]
+	replicas[provider] = urls
+	t.Cleanup(func() {
+		if ok {
+			replicas[provider] = saved
+		} else {
+			delete(replicas, provider)
+		}
+	})
+}
+
+func TestWeatherMinMax(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"main": {"temp": 285.15, "temp_min": 283.15, "temp_max": 288.15}}`)
+	}))
+	defer server.Close()
+	useReplicas(t, "openweathermap", server.URL)
+
+	tests := []struct {
+		name    string
+		group   multiWeatherProvider
+		wantMin interface{}
+		wantMax interface{}
+	}{
+		{"reported", multiWeatherProvider{openWeatherMap{}}, 10.0, 15.0},
+		{"averaged with a provider without them", multiWeatherProvider{openWeatherMap{}, reading("fake", 285.15)}, 10.0, 15.0},
+		{"not reported", multiWeatherProvider{reading("fake", 285.15)}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		useGroups(t, tt.group)
+		_, body := getWeather(t, "/weather/Paris?units=c", nil)
+		if body["temp_min"] != tt.wantMin || body["temp_max"] != tt.wantMax {
+			t.Errorf("%s: temp_min %v and temp_max %v, want %v and %v",
+				tt.name, body["temp_min"], body["temp_max"], tt.wantMin, tt.wantMax)
+		}
+	}
+}
+
+func TestWeatherDisagreementWarning(t *testing.T) {
+	defer func(threshold float64) { disagreementThreshold = threshold }(disagreementThreshold)
+
+	tests := []struct {
+		name      string
+		threshold float64
+		kelvins   []float64
+		want      bool
+	}{
+		{"beyond the threshold", 5, []float64{280, 283, 286}, true},
+		{"at the threshold", 5, []float64{280, 285}, false},
+		{"within the threshold", 5, []float64{280, 282}, false},
+		{"disabled", 0, []float64{250, 300}, false},
+		{"single provider", 5, []float64{280}, false},
+	}
+
+	for _, tt := range tests {
+		disagreementThreshold = tt.threshold
+		var group multiWeatherProvider
+		for i, k := range tt.kelvins {
+			group = append(group, reading(fmt.Sprintf("p%d", i), k))
+		}
+		useGroups(t, group)
+
+		rec, body := getWeather(t, "/weather/Paris", nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", tt.name, rec.Code)
+		}
+		w, ok := body["warning"].(map[string]interface{})
+		if ok != tt.want {
+			t.Errorf("%s: warning = %v, want one %v", tt.name, body["warning"], tt.want)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		last := fmt.Sprintf("p%d", len(tt.kelvins)-1)
+		if p := w["providers"].([]interface{}); len(p) != 2 || p[0] != last || p[1] != "p0" {
+			t.Errorf("%s: warning names %v, want [%s p0]", tt.name, p, last)
+		}
+		if w["spread_k"] != 6.0 {
+			t.Errorf("%s: spread_k = %v, want 6", tt.name, w["spread_k"])
+		}
+	}
+}
+
+func TestAttributions(t *testing.T) {
+	owm := providers["openweathermap"].attribution()
+	wu := providers["weatherunderground"].attribution()
+
+	tests := []struct {
+		name      string
+		providers []string
+		want      []string
+	}{
+		{"both", []string{"weatherunderground", "openweathermap"}, []string{wu, owm}},
+		{"repeated", []string{"openweathermap", "openweathermap"}, []string{owm}},
+		{"unregistered", []string{"fake", "weatherunderground"}, []string{wu}},
+		{"none", nil, nil},
+	}
+
+	for _, tt := range tests {
+		var obs []observation
+		for _, p := range tt.providers {
+			obs = append(obs, observation{provider: p})
+		}
+		got := attributions(obs)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: attributions = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWeatherAttributesContributingProviders(t *testing.T) {
+	useGroups(t, multiWeatherProvider{failing("openweathermap"), reading("weatherunderground", 290)})
+
+	_, body := getWeather(t, "/weather/Paris", nil)
+	got, _ := body["attribution"].([]interface{})
+	if len(got) != 1 || got[0] != providers["weatherunderground"].attribution() {
+		t.Errorf("attribution = %v, want only Weather Underground's", body["attribution"])
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	window := time.Minute
+	at := time.Date(2026, 10, 14, 12, 0, 10, 0, time.UTC)
+	base := fingerprint("London", unitsCelsius, 0, at, window)
+
+	tests := []struct {
+		name    string
+		city    string
+		units   string
+		sigfigs int
+		at      time.Time
+		same    bool
+	}{
+		{"identical", "London", unitsCelsius, 0, at, true},
+		{"later in the bucket", "London", unitsCelsius, 0, at.Add(45 * time.Second), true},
+		{"differently written", " london ", unitsCelsius, 0, at, true},
+		{"next bucket", "London", unitsCelsius, 0, at.Add(time.Minute), false},
+		{"other units", "London", unitsFahrenheit, 0, at, false},
+		{"other precision", "London", unitsCelsius, 3, at, false},
+		{"other city", "Paris", unitsCelsius, 0, at, false},
+	}
+
+	for _, tt := range tests {
+		if same := fingerprint(tt.city, tt.units, tt.sigfigs, tt.at, window) == base; same != tt.same {
+			t.Errorf("%s: same fingerprint = %v, want %v", tt.name, same, tt.same)
+		}
+	}
+}
+
+func TestWeatherEchoesIdempotencyKey(t *testing.T) {
+	useGroups(t, multiWeatherProvider{reading("fake", 290)})
+
+	rec, body := getWeather(t, "/weather/London", http.Header{"Idempotency-Key": {"retry-42"}})
+	if got := rec.Header().Get("Idempotency-Key"); got != "retry-42" {
+		t.Errorf("Idempotency-Key = %q, want it echoed", got)
+	}
+	if got, want := rec.Header().Get("ETag"), `W/"`+body["fingerprint"].(string)+`"`; got != want {
+		t.Errorf("ETag = %q, want %q from the fingerprint", got, want)
+	}
+}
+
+func TestWeatherDataAgeWarning(t *testing.T) {
+	defer func(d time.Duration) { staleAfter = d }(staleAfter)
+	staleAfter = 2 * time.Hour
+
+	observedAgo := func(id string, age time.Duration) *fakeProvider {
+		at := time.Now().Add(-age).Truncate(time.Second)
+		return &fakeProvider{id: id, obs: observation{kelvin: 290, observed: &at}}
+	}
+
+	tests := []struct {
+		name       string
+		group      multiWeatherProvider
+		wantOldest time.Duration // zero when no warning is wanted
+	}{
+		{"every reading old", multiWeatherProvider{observedAgo("a", 3*time.Hour), observedAgo("b", 5*time.Hour)}, 5 * time.Hour},
+		{"one reading fresh", multiWeatherProvider{observedAgo("a", 3*time.Hour), observedAgo("b", time.Minute)}, 0},
+		{"one reading undated", multiWeatherProvider{observedAgo("a", 3*time.Hour), reading("b", 290)}, 0},
+		{"every reading fresh", multiWeatherProvider{observedAgo("a", time.Minute)}, 0},
+	}
+
+	for _, tt := range tests {
+		useGroups(t, tt.group)
+		rec, body := getWeather(t, "/weather/Paris", nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", tt.name, rec.Code)
+		}
+		if tt.wantOldest == 0 {
+			if _, ok := body["data_age_warning"]; ok {
+				t.Errorf("%s: got data_age_warning, want none", tt.name)
+			}
+			continue
+		}
+		if body["data_age_warning"] != true {
+			t.Errorf("%s: data_age_warning = %v, want true", tt.name, body["data_age_warning"])
+			continue
+		}
+		oldest, err := time.Parse(time.RFC3339, body["oldest_observation"].(string))
+		if want := time.Now().Add(-tt.wantOldest); err != nil || oldest.Sub(want).Abs() > 2*time.Second {
+			t.Errorf("%s: oldest_observation = %v, want %s", tt.name, body["oldest_observation"], want)
+		}
+	}
+}
+
+func TestFreshnessMean(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	fetched := func(kelvin float64, age time.Duration) observation {
+		return observation{kelvin: kelvin, fetched: now.Add(-age)}
+	}
+	fresh, stale := fetched(290, 0), fetched(280, 10*time.Minute)
+
+	tests := []struct {
+		name     string
+		obs      []observation
+		halfLife time.Duration
+		want     float64
+	}{
+		{"equal weights without a half-life", []observation{fresh, stale}, 0, 285},
+		{"stale reading at one half-life", []observation{fresh, stale}, 10 * time.Minute, (290 + 0.5*280) / 1.5},
+		{"stale reading at two half-lives", []observation{fresh, stale}, 5 * time.Minute, (290 + 0.25*280) / 1.25},
+		{"equally old readings", []observation{fetched(290, time.Hour), fetched(280, time.Hour)}, time.Minute, 285},
+		{"fetched in the future", []observation{fetched(290, -time.Minute), stale}, 10 * time.Minute, (290 + 0.5*280) / 1.5},
+		// Thousands of half-lives old: weighted by age from now, both
+		// weights would underflow to zero.
+		{"every reading long stale", []observation{fetched(290, 30*time.Minute), fetched(280, 40*time.Minute)}, time.Second, 290},
+		{"every reading long stale, equally", []observation{fetched(290, 30*time.Minute), fetched(280, 30*time.Minute)}, time.Second, 285},
+		{"one reading long stale", []observation{fetched(290, 30*time.Minute)}, time.Second, 290},
+	}
+
+	for _, tt := range tests {
+		if got := freshnessMean(tt.obs, tt.halfLife, now); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: freshnessMean = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// The fresh reading pulls the aggregate towards it.
+	if got := freshnessMean([]observation{fresh, stale}, 10*time.Minute, now); got <= 285 {
+		t.Errorf("weighted mean %v is not above the plain mean 285", got)
+	}
+}
+
+func TestWeatherLongStaleCachedReadings(t *testing.T) {
+	defer func(h time.Duration) { freshnessHalfLife = h }(freshnessHalfLife)
+	freshnessHalfLife = time.Second
+	useCacheTTL(t, time.Hour)
+	useGroups(t, multiWeatherProvider{reading("a", 300), reading("b", 300)})
+	old := time.Now().Add(-30 * time.Minute)
+	cache.set("a", "Rome", observation{provider: "a", kelvin: 290, fetched: old})
+	cache.set("b", "Rome", observation{provider: "b", kelvin: 280, fetched: old})
+
+	rec, body := getWeather(t, "/weather/Rome?units=k", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := body["temp_k"]; got != 285.0 {
+		t.Errorf("temp_k = %v, want 285", got)
+	}
+}
+
+func TestWeatherCacheHeaders(t *testing.T) {
+	useGroups(t, multiWeatherProvider{reading("fake", 290)})
+
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		header      http.Header
+		wantControl string
+	}{
+		{"shared", time.Minute, nil, "max-age=60"},
+		{"client token", time.Minute, http.Header{"X-Client-Token": {"kiosk-1"}}, "private, max-age=60"},
+		{"request ID", time.Minute, http.Header{"X-Request-Id": {"req-1"}}, "private, max-age=60"},
+		{"client key", time.Minute, http.Header{"X-Provider-Key": {"weatherunderground=abc123"}}, "private, max-age=60"},
+		{"no caching", 0, nil, ""},
+		{"no caching with a client token", 0, http.Header{"X-Client-Token": {"kiosk-1"}}, "private"},
+	}
+
+	for _, tt := range tests {
+		useCacheTTL(t, tt.ttl)
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			req := httptest.NewRequest(method, "/weather/London", nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			rec := httptest.NewRecorder()
+			weather(rec, req)
+
+			if got := rec.Header().Get("Cache-Control"); got != tt.wantControl {
+				t.Errorf("%s %s: Cache-Control = %q, want %q", tt.name, method, got, tt.wantControl)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Language" {
+				t.Errorf("%s %s: Vary = %q, want Accept-Language", tt.name, method, got)
+			}
+			if method == http.MethodHead && (rec.Code != http.StatusNoContent || rec.Body.Len() != 0) {
+				t.Errorf("%s HEAD: status %d with %d bytes, want 204 and no body", tt.name, rec.Code, rec.Body.Len())
+			}
+		}
+	}
+}
+
+func TestWeatherETagVaries(t *testing.T) {
+	useGroups(t, multiWeatherProvider{reading("fake", 290)})
+	// A day-long window keeps every request in one fingerprint bucket.
+	useCacheTTL(t, 24*time.Hour)
+
+	etag := func(path, lang string) string {
+		req := httptest.NewRequest(http.MethodHead, path, nil)
+		req.Header.Set("Accept-Language", lang)
+		rec := httptest.NewRecorder()
+		weather(rec, req)
+		return rec.Header().Get("ETag")
+	}
+
+	base := etag("/weather/London", "en-GB")
+	tests := []struct {
+		path, lang string
+		same       bool
+	}{
+		{"/weather/London", "en-GB", true},
+		{"/weather/London?units=c", "en-US", true},
+		{"/weather/London", "en-US", false},
+		{"/weather/London?sigfigs=3", "en-GB", false},
+	}
+
+	for _, tt := range tests {
+		if same := etag(tt.path, tt.lang) == base; same != tt.same {
+			t.Errorf("%s with %s: same ETag = %v, want %v", tt.path, tt.lang, same, tt.same)
+		}
+	}
+}
+
+func TestAsOf(t *testing.T) {
+	base := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	fallback := base.Add(time.Hour)
+	observedAt := func(minutes ...int) []observation {
+		var list []observation
+		for _, m := range minutes {
+			if m < 0 {
+				list = append(list, observation{})
+				continue
+			}
+			at := base.Add(time.Duration(m) * time.Minute)
+			list = append(list, observation{observed: &at})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name string
+		obs  []observation
+		want time.Time
+	}{
+		{"single", observedAt(10), base.Add(10 * time.Minute)},
+		{"odd count", observedAt(30, 0, 10), base.Add(10 * time.Minute)},
+		{"even count", observedAt(0, 20, 10, 50), base.Add(15 * time.Minute)},
+		{"outlier", observedAt(0, 1, 2, 600), base.Add(90 * time.Second)},
+		{"undated readings ignored", observedAt(-1, 5, -1), base.Add(5 * time.Minute)},
+		{"no dated readings", observedAt(-1, -1), fallback},
+		{"no readings", nil, fallback},
+	}
+
+	for _, tt := range tests {
+		if got := asOf(tt.obs, fallback); !got.Equal(tt.want) {
+			t.Errorf("%s: asOf = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
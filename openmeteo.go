@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// maxSeriesHours is the furthest back Open-Meteo's forecast API serves
+// recent hourly data (92 days).
+const maxSeriesHours = 92 * 24
+
+// location is a geocoded place.
+type location struct {
+	Name       string  `json:"name"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	Country    string  `json:"country_code"`
+	Population int     `json:"population"`
+	Timezone   string  `json:"timezone"`
+}
+
+// A geocoder resolves the name of a city to its coordinates.
+type geocoder interface {
+	geocode(ctx context.Context, city string) (location, error)
+}
+
+// openMeteo queries the Open-Meteo geocoding and forecast APIs.
+type openMeteo struct{}
+
+var om openMeteo
+
+// openMeteoAttribution is required by Open-Meteo's CC BY 4.0 licence.
+const openMeteoAttribution = "Weather data by Open-Meteo.com"
+
+// Base URLs of Open-Meteo's geocoding and forecast APIs.
+var (
+	openMeteoGeocodingURL = "https://geocoding-api.open-meteo.com"
+	openMeteoForecastURL  = "https://api.open-meteo.com"
+)
+
+// places resolves city names for the coordinate-based endpoints.
+var places geocoder = newCoalescingGeocoder(om)
+
+// Policies for choosing between several geocoding candidates.
+const (
+	pickFirst      = "first"      // the geocoder's best match
+	pickPopulation = "population" // the most populous match
+	pickUnique     = "unique"     // fail unless there is exactly one match
+)
+
+// geocodePolicy is the policy used to pick a geocoding candidate.
+var geocodePolicy = pickFirst
+
+func (o openMeteo) geocode(ctx context.Context, city string) (location, error) {
+	resp, err := fetch(ctx, openMeteoGeocodingURL+"/v1/search?count=10&name="+url.QueryEscape(city))
+	if err != nil {
+		return location{}, err
+	}
+
+	defer resp.Body.Close()
+
+	var d struct {
+		Results []location `json:"results"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return location{}, err
+	}
+
+	l, err := pickCandidate(city, d.Results, geocodePolicy)
+	if err != nil {
+		return location{}, err
+	}
+
+	logf(ctx, "Open-Meteo geocoded %s to %.4f,%.4f\n", city, l.Latitude, l.Longitude)
+
+	return l, nil
+}
+
+// checkOpenMeteo returns an error for a response that is not a success,
+// with the reason Open-Meteo gives in its error body when there is one.
+func checkOpenMeteo(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var d struct {
+		Reason string `json:"reason"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&d) == nil && d.Reason != "" {
+		return fmt.Errorf("Open-Meteo responded %s: %s", resp.Status, d.Reason)
+	}
+	return fmt.Errorf("Open-Meteo responded %s", resp.Status)
+}
+
+// pickCandidate chooses one of the geocoding candidates for city according
+// to policy.
+func pickCandidate(city string, candidates []location, policy string) (location, error) {
+	if len(candidates) == 0 {
+		return location{}, fmt.Errorf("unable to find %s", city)
+	}
+
+	switch policy {
+	case pickFirst:
+		return candidates[0], nil
+	case pickPopulation:
+		best := candidates[0]
+		for _, c := range candidates[1:] {
+			if c.Population > best.Population {
+				best = c
+			}
+		}
+		return best, nil
+	case pickUnique:
+		if len(candidates) > 1 {
+			return location{}, fmt.Errorf("%s is ambiguous: %d places match", city, len(candidates))
+		}
+		return candidates[0], nil
+	}
+	return location{}, fmt.Errorf("unknown geocoding policy %q", policy)
+}
+
+// seriesPoint is a single hourly temperature, in Kelvin.
+type seriesPoint struct {
+	time   string
+	kelvin float64
+}
+
+// series returns the hourly temperatures at l for the past hours hours.
+func (o openMeteo) series(ctx context.Context, l location, hours int) ([]seriesPoint, error) {
+	resp, err := fetch(ctx, fmt.Sprintf(
+		"%s/v1/forecast?latitude=%f&longitude=%f&hourly=temperature_2m&past_hours=%d&forecast_hours=0",
+		openMeteoForecastURL, l.Latitude, l.Longitude, hours))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if err := checkOpenMeteo(resp); err != nil {
+		return nil, err
+	}
+
+	times, celsius, err := decodeHourly(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(times) != len(celsius) {
+		return nil, errors.New("Open-Meteo returned mismatched hourly data")
+	}
+
+	points := make([]seriesPoint, 0, len(times))
+	for i := range times {
+		if celsius[i] == nil {
+			continue
+		}
+		points = append(points, seriesPoint{time: times[i], kelvin: celsiusToKelvin(*celsius[i])})
+	}
+
+	logf(ctx, "Open-Meteo responded with %d hourly readings for %.4f,%.4f\n", len(points), l.Latitude, l.Longitude)
+
+	return points, nil
+}
+
+// decodeHourly streams an Open-Meteo forecast body and extracts only the
+// hourly time and temperature_2m arrays, skipping every other field without
+// decoding it. Missing temperatures are returned as nil. A body without
+// both arrays, such as an error body, is an error.
+func decodeHourly(r io.Reader) ([]string, []*float64, error) {
+	dec := json.NewDecoder(r)
+
+	var times []string
+	var celsius []*float64
+	var hasTimes, hasCelsius bool
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, nil, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		if key != "hourly" {
+			if err := skipValue(dec); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		if err := expectDelim(dec, '{'); err != nil {
+			return nil, nil, err
+		}
+		for dec.More() {
+			field, err := dec.Token()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			switch field {
+			case "time":
+				hasTimes = true
+				err = decodeArray(dec, func() error {
+					var t string
+					err := dec.Decode(&t)
+					times = append(times, t)
+					return err
+				})
+			case "temperature_2m":
+				hasCelsius = true
+				err = decodeArray(dec, func() error {
+					var c *float64
+					err := dec.Decode(&c)
+					celsius = append(celsius, c)
+					return err
+				})
+			default:
+				err = skipValue(dec)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := expectDelim(dec, '}'); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, nil, err
+	}
+	if !hasTimes || !hasCelsius {
+		return nil, nil, errors.New("Open-Meteo returned no hourly time and temperature_2m data")
+	}
+	return times, celsius, nil
+}
+
+// decodeArray reads a JSON array, calling element once per element to
+// decode it.
+func decodeArray(dec *json.Decoder, element func() error) error {
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+	for dec.More() {
+		if err := element(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// expectDelim reads the next token and fails unless it is delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("expected %v in JSON, got %v", delim, t)
+	}
+	return nil
+}
+
+// skipValue reads past the next JSON value, however deeply nested.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// series is the http handler for /series. It returns the hourly temperature
+// for the past hours hours (default 24) for the given city.
+func series(writer http.ResponseWriter, req *http.Request) {
+	begin := time.Now()
+	query := req.URL.Query()
+
+	city := query.Get("city")
+	if city == "" {
+		writeError(writer, errMissingCity, "a city must be given")
+		return
+	}
+
+	hours := 24
+	if h := query.Get("hours"); h != "" {
+		n, err := strconv.Atoi(h)
+		if err != nil || n < 1 || n > maxSeriesHours {
+			writeError(writer, errInvalidParameter, fmt.Sprintf("hours must be between 1 and %d", maxSeriesHours))
+			return
+		}
+		hours = n
+	}
+
+	units, err := requestUnits(req)
+	if err != nil {
+		writeError(writer, errInvalidParameter, err.Error())
+		return
+	}
+
+	l, err := places.geocode(req.Context(), city)
+	if err != nil {
+		writeError(writer, errUpstream, err.Error())
+		return
+	}
+
+	points, err := om.series(req.Context(), l, hours)
+	if err != nil {
+		writeError(writer, errUpstream, err.Error())
+		return
+	}
+
+	temps := make([]map[string]interface{}, len(points))
+	for i, p := range points {
+		temps[i] = map[string]interface{}{
+			"time": p.time,
+			"temp": fromKelvin(p.kelvin, units),
+		}
+	}
+
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"city":        city,
+		"units":       units,
+		"series":      temps,
+		"attribution": []string{openMeteoAttribution},
+		"took":        formatTook(req, time.Since(begin)),
+	})
+}
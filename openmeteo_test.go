@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeGeocoder places every city at loc, or fails when err is set, and
+// counts its lookups.
+type fakeGeocoder struct {
+	loc   location
+	err   error
+	calls int64
+}
+
+func (g *fakeGeocoder) geocode(ctx context.Context, city string) (location, error) {
+	atomic.AddInt64(&g.calls, 1)
+	if g.err != nil {
+		return location{}, g.err
+	}
+	l := g.loc
+	l.Name = city
+	return l, nil
+}
+
+// usePlaces makes the coordinate-based endpoints geocode with g until the
+// test ends.
+func usePlaces(t *testing.T, g geocoder) {
+	t.Helper()
+	saved := places
+	places = g
+	t.Cleanup(func() { places = saved })
+}
+
+// useForecastAPI points Open-Meteo's forecast API at h until the test ends.
+func useForecastAPI(t *testing.T, h http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(h)
+	saved := openMeteoForecastURL
+	openMeteoForecastURL = server.URL
+	t.Cleanup(func() {
+		openMeteoForecastURL = saved
+		server.Close()
+	})
+}
+
+func TestPickCandidate(t *testing.T) {
+	candidates := []location{
+		{Name: "Paris", Country: "FR", Population: 2138551},
+		{Name: "Paris", Country: "US", Population: 25171},
+		{Name: "Paris", Country: "CA", Population: 11000},
+	}
+	small := []location{{Name: "Paris", Country: "US", Population: 25171}}
+
+	tests := []struct {
+		policy      string
+		candidates  []location
+		wantCountry string
+		wantErr     bool
+	}{
+		{pickFirst, candidates, "FR", false},
+		{pickPopulation, append([]location{candidates[2]}, candidates[:2]...), "FR", false},
+		{pickUnique, candidates, "", true},
+		{pickUnique, small, "US", false},
+		{pickFirst, nil, "", true},
+		{"nearest", candidates, "", true},
+	}
+
+	for _, tt := range tests {
+		l, err := pickCandidate("Paris", tt.candidates, tt.policy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s of %d: err = %v, want error %v", tt.policy, len(tt.candidates), err, tt.wantErr)
+			continue
+		}
+		if l.Country != tt.wantCountry {
+			t.Errorf("%s of %d: picked %s, want %s", tt.policy, len(tt.candidates), l.Country, tt.wantCountry)
+		}
+	}
+}
+
+func TestGeocodePolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") != "Paris" {
+			fmt.Fprint(w, `{}`)
+			return
+		}
+		fmt.Fprint(w, `{"results": [
+			{"name": "Paris", "latitude": 33.66, "longitude": -95.55, "country_code": "US", "population": 25171},
+			{"name": "Paris", "latitude": 48.85, "longitude": 2.35, "country_code": "FR", "population": 2138551}
+		]}`)
+	}))
+	defer server.Close()
+
+	defer func(u, policy string) { openMeteoGeocodingURL, geocodePolicy = u, policy }(openMeteoGeocodingURL, geocodePolicy)
+	openMeteoGeocodingURL = server.URL
+
+	tests := []struct {
+		policy, city string
+		wantCountry  string
+		wantErr      bool
+	}{
+		{pickFirst, "Paris", "US", false},
+		{pickPopulation, "Paris", "FR", false},
+		{pickUnique, "Paris", "", true},
+		{pickFirst, "Atlantis", "", true},
+	}
+
+	for _, tt := range tests {
+		geocodePolicy = tt.policy
+		l, err := om.geocode(context.Background(), tt.city)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s %s: err = %v, want error %v", tt.policy, tt.city, err, tt.wantErr)
+			continue
+		}
+		if l.Country != tt.wantCountry {
+			t.Errorf("%s %s: geocoded to %s, want %s", tt.policy, tt.city, l.Country, tt.wantCountry)
+		}
+	}
+}
+
+func TestDecodeHourly(t *testing.T) {
+	body := `{
+		"latitude": 51.5,
+		"hourly_units": {"time": "iso8601", "temperature_2m": "°C"},
+		"nested": {"a": [1, {"b": [2, 3]}], "c": null},
+		"hourly": {
+			"relative_humidity_2m": [80, 81, 82],
+			"time": ["2026-10-14T00:00", "2026-10-14T01:00", "2026-10-14T02:00"],
+			"temperature_2m": [10.5, null, 9.75]
+		},
+		"elevation": 11
+	}`
+
+	times, celsius, err := decodeHourly(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*float64{ptr(10.5), nil, ptr(9.75)}
+	if len(times) != 3 || times[1] != "2026-10-14T01:00" || len(celsius) != len(want) {
+		t.Fatalf("decoded %v and %d temperatures", times, len(celsius))
+	}
+	for i := range want {
+		if (celsius[i] == nil) != (want[i] == nil) || celsius[i] != nil && *celsius[i] != *want[i] {
+			t.Errorf("temperature %d = %v, want %v", i, celsius[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{
+		`[]`,
+		`{"hourly": {"time": "x"}}`,
+		`{"hourly": [`,
+		`{}`,
+		`{"hourly": {}}`,
+		`{"hourly": {"time": []}}`,
+		`{"hourly": {"temperature_2m": []}}`,
+		`{"error": true, "reason": "Parameter 'past_hours' is out of range"}`,
+	} {
+		if _, _, err := decodeHourly(strings.NewReader(bad)); err == nil {
+			t.Errorf("decodeHourly(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func ptr(v float64) *float64 { return &v }
+
+func TestSeries(t *testing.T) {
+	usePlaces(t, &fakeGeocoder{loc: location{Latitude: 51.5, Longitude: -0.12}})
+
+	var pastHours string
+	useForecastAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		pastHours = r.URL.Query().Get("past_hours")
+		fmt.Fprint(w, `{"hourly": {
+			"time": ["2026-10-14T00:00", "2026-10-14T01:00", "2026-10-14T02:00"],
+			"temperature_2m": [10, null, -40]
+		}}`)
+	})
+
+	tests := []struct {
+		query     string
+		wantUnits string
+		wantTemps []float64
+	}{
+		{"city=London&hours=3&units=c", unitsCelsius, []float64{10, -40}},
+		{"city=London&hours=3&units=f", unitsFahrenheit, []float64{50, -40}},
+		{"city=London&hours=3&units=k", unitsKelvin, []float64{283.15, 233.15}},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		series(rec, httptest.NewRequest(http.MethodGet, "/series?"+tt.query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", tt.query, rec.Code, rec.Body)
+		}
+		if pastHours != "3" {
+			t.Errorf("%s: asked Open-Meteo for %s past hours, want 3", tt.query, pastHours)
+		}
+
+		var body struct {
+			Units  string `json:"units"`
+			Series []struct {
+				Time string  `json:"time"`
+				Temp float64 `json:"temp"`
+			} `json:"series"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Units != tt.wantUnits {
+			t.Errorf("%s: units = %s, want %s", tt.query, body.Units, tt.wantUnits)
+		}
+		if len(body.Series) != len(tt.wantTemps) {
+			t.Fatalf("%s: got %d points, want %d", tt.query, len(body.Series), len(tt.wantTemps))
+		}
+		for i, want := range tt.wantTemps {
+			if body.Series[i].Temp != want {
+				t.Errorf("%s: point %d is %v, want %v", tt.query, i, body.Series[i].Temp, want)
+			}
+		}
+		if body.Series[1].Time != "2026-10-14T02:00" {
+			t.Errorf("%s: the missing reading was not skipped: %v", tt.query, body.Series)
+		}
+	}
+}
+
+func TestSeriesValidatesHours(t *testing.T) {
+	usePlaces(t, &fakeGeocoder{})
+	useForecastAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"hourly": {"time": [], "temperature_2m": []}}`)
+	})
+
+	tests := []struct {
+		query      string
+		wantStatus int
+	}{
+		{"city=London", http.StatusOK},
+		{"city=London&hours=1", http.StatusOK},
+		{fmt.Sprintf("city=London&hours=%d", maxSeriesHours), http.StatusOK},
+		{fmt.Sprintf("city=London&hours=%d", maxSeriesHours+1), http.StatusBadRequest},
+		{"city=London&hours=0", http.StatusBadRequest},
+		{"city=London&hours=day", http.StatusBadRequest},
+		{"hours=3", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		series(rec, httptest.NewRequest(http.MethodGet, "/series?"+tt.query, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.query, rec.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestSeriesUpstreamErrors(t *testing.T) {
+	usePlaces(t, &fakeGeocoder{})
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"rate limited", http.StatusTooManyRequests, `{"error": true, "reason": "Too many requests"}`},
+		{"bad request", http.StatusBadRequest, `{"error": true, "reason": "Parameter 'past_hours' is out of range"}`},
+		{"error body with 200", http.StatusOK, `{"error": true, "reason": "Cannot initialize WeatherVariable"}`},
+		{"server error", http.StatusBadGateway, `<html>Bad Gateway</html>`},
+	}
+
+	for _, tt := range tests {
+		useForecastAPI(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		})
+
+		rec := httptest.NewRecorder()
+		series(rec, httptest.NewRequest(http.MethodGet, "/series?city=London&hours=3", nil))
+		if rec.Code != errUpstream.Status || !strings.Contains(rec.Body.String(), errUpstream.Code) {
+			t.Errorf("%s: status = %d: %s, want %s", tt.name, rec.Code, rec.Body, errUpstream.Code)
+		}
+	}
+}
+
+// largeForecast returns a forecast body with hours hourly readings of
+// temperature and a dozen other variables the decoder has to skip.
+func largeForecast(hours int) string {
+	var b strings.Builder
+	b.WriteString(`{"latitude": 51.5, "longitude": -0.12, "hourly_units": {"time": "iso8601"}, "hourly": {`)
+	for v := 0; v < 12; v++ {
+		fmt.Fprintf(&b, `"variable_%d": [`, v)
+		for h := 0; h < hours; h++ {
+			if h > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, "%d.5", h%100)
+		}
+		b.WriteString("],")
+	}
+	b.WriteString(`"time": [`)
+	for h := 0; h < hours; h++ {
+		if h > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `"h%d"`, h)
+	}
+	b.WriteString(`], "temperature_2m": [`)
+	for h := 0; h < hours; h++ {
+		if h > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "%d", h%40-10)
+	}
+	b.WriteString("]}}")
+	return b.String()
+}
+
+func TestDecodeHourlyLargeBody(t *testing.T) {
+	times, celsius, err := decodeHourly(strings.NewReader(largeForecast(maxSeriesHours)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != maxSeriesHours || len(celsius) != maxSeriesHours {
+		t.Fatalf("decoded %d times and %d temperatures, want %d", len(times), len(celsius), maxSeriesHours)
+	}
+	for _, h := range []int{0, 1, 39, 40, maxSeriesHours - 1} {
+		if times[h] != fmt.Sprintf("h%d", h) || *celsius[h] != float64(h%40-10) {
+			t.Errorf("hour %d decoded as %s %v", h, times[h], *celsius[h])
+		}
+	}
+}
+
+func BenchmarkDecodeHourly(b *testing.B) {
+	body := largeForecast(maxSeriesHours)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := decodeHourly(strings.NewReader(body)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// lastSuccess records when each provider last answered successfully.
+var lastSuccess = &successLog{at: map[string]time.Time{}}
+
+type successLog struct {
+	mu sync.Mutex
+	at map[string]time.Time
+}
+
+// record notes that the provider has just answered successfully.
+func (s *successLog) record(provider string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.at[provider] = time.Now()
+}
+
+// get returns when the provider last answered successfully, if ever.
+func (s *successLog) get(provider string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.at[provider]
+	return t, ok
+}
+
+// providersHandler lists the configured providers by group, with when each
+// last succeeded and whether it is cooling down.
+func providersHandler(writer http.ResponseWriter, req *http.Request) {
+	var list []map[string]interface{}
+	for i, group := range groups {
+		for _, p := range group {
+			entry := map[string]interface{}{
+				"name":         p.name(),
+				"group":        i + 1,
+				"attribution":  p.attribution(),
+				"last_success": nil,
+			}
+			if t, ok := lastSuccess.get(p.name()); ok {
+				entry["last_success"] = t
+			}
+			if until, ok := cooldowns.active(p.name()); ok {
+				entry["cooling_down_until"] = until
+			}
+			list = append(list, entry)
+		}
+	}
+
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"providers": list,
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLastSuccessAdvances(t *testing.T) {
+	ok := reading("last-success-ok", 290)
+	down := failing("last-success-down")
+	group := multiWeatherProvider{ok, down}
+
+	group.readings(context.Background(), "Paris")
+	first, found := lastSuccess.get(ok.id)
+	if !found {
+		t.Fatal("no last success recorded after a successful call")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	group.readings(context.Background(), "Paris")
+	second, _ := lastSuccess.get(ok.id)
+	if !second.After(first) {
+		t.Errorf("last success went from %s to %s, want it to advance", first, second)
+	}
+
+	if at, found := lastSuccess.get(down.id); found {
+		t.Errorf("a provider that never answered has a last success of %s", at)
+	}
+}
+
+func TestProvidersHandler(t *testing.T) {
+	ok := reading("providers-ok", 290)
+	down := failing("providers-down")
+	useGroups(t, multiWeatherProvider{ok}, multiWeatherProvider{down})
+	lastSuccess.record(ok.id)
+
+	rec := httptest.NewRecorder()
+	providersHandler(rec, httptest.NewRequest(http.MethodGet, "/providers", nil))
+
+	var body struct {
+		Providers []struct {
+			Name        string     `json:"name"`
+			Group       int        `json:"group"`
+			LastSuccess *time.Time `json:"last_success"`
+		} `json:"providers"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Providers) != 2 {
+		t.Fatalf("listed %d providers, want 2", len(body.Providers))
+	}
+
+	tests := []struct {
+		name        string
+		group       int
+		wantSuccess bool
+	}{
+		{ok.id, 1, true},
+		{down.id, 2, false},
+	}
+	for i, tt := range tests {
+		p := body.Providers[i]
+		if p.Name != tt.name || p.Group != tt.group || (p.LastSuccess != nil) != tt.wantSuccess {
+			t.Errorf("provider %d = %s in group %d with last success %v, want %s in group %d with one %v",
+				i, p.Name, p.Group, p.LastSuccess, tt.name, tt.group, tt.wantSuccess)
+		}
+	}
+}
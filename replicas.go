@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// replicas lists the base URLs each provider can be reached at. When a
+// provider has several, every one is asked and the fastest answer is used.
+var replicas = map[string][]string{
+	"openweathermap":     {"http://api.openweathermap.org"},
+	"weatherunderground": {"http://api.wunderground.com"},
+}
+
+// cancelBody cancels its request's context once the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// upstreamRequest is a call to a provider's API, relative to its base URL.
+type upstreamRequest struct {
+	method      string // GET when empty
+	path        string
+	contentType string
+	body        []byte
+}
+
+// jsonRequest builds a POST of body, encoded as JSON, to path.
+func jsonRequest(path string, body interface{}) (upstreamRequest, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return upstreamRequest{}, err
+	}
+	return upstreamRequest{
+		method:      http.MethodPost,
+		path:        path,
+		contentType: "application/json",
+		body:        b,
+	}, nil
+}
+
+// build creates the http.Request for r against the base URL.
+func (r upstreamRequest) build(ctx context.Context, base string) (*http.Request, error) {
+	method := r.method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, base+r.path, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	return req, nil
+}
+
+// hedgedDo sends r to every replica of the provider at once and returns the
+// first successful (2xx) response, cancelling the rest. Any other status,
+// such as a 429 from one rate-limited replica, counts as that replica
+// failing. When every replica fails, the last response that arrived (or
+// error) is returned. The requests are cancelled along with ctx.
+func hedgedDo(ctx context.Context, provider string, r upstreamRequest) (*http.Response, error) {
+	urls := replicas[provider]
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no URL configured for %s", provider)
+	}
+	if len(urls) == 1 {
+		req, err := r.build(ctx, urls[0])
+		if err != nil {
+			return nil, err
+		}
+		return client.Do(req)
+	}
+
+	type result struct {
+		replica int
+		resp    *http.Response
+		err     error
+	}
+
+	results := make(chan result, len(urls))
+	cancels := make([]context.CancelFunc, len(urls))
+	for i, base := range urls {
+		replicaCtx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+
+		go func(i int, base string) {
+			req, err := r.build(replicaCtx, base)
+			if err != nil {
+				results <- result{i, nil, err}
+				return
+			}
+			resp, err := client.Do(req)
+			results <- result{i, resp, err}
+		}(i, base)
+	}
+
+	var lastResp *http.Response
+	var lastErr error
+	lastReplica := -1
+	for n := 1; n <= len(urls); n++ {
+		res := <-results
+		if res.err != nil {
+			lastErr = res.err
+			continue
+		}
+
+		if res.resp.StatusCode >= 200 && res.resp.StatusCode < 300 {
+			if lastResp != nil {
+				lastResp.Body.Close()
+			}
+			for i, cancel := range cancels {
+				if i != res.replica {
+					cancel()
+				}
+			}
+			// Close whatever the slower replicas manage to return.
+			go func(remaining int) {
+				for ; remaining > 0; remaining-- {
+					if late := <-results; late.err == nil {
+						late.resp.Body.Close()
+					}
+				}
+			}(len(urls) - n)
+
+			logf(ctx, "Replica %s answered first for %s\n", urls[res.replica], provider)
+			res.resp.Body = cancelBody{res.resp.Body, cancels[res.replica]}
+			return res.resp, nil
+		}
+
+		if lastResp != nil {
+			lastResp.Body.Close()
+		}
+		lastResp, lastReplica = res.resp, res.replica
+		res.resp.Body = cancelBody{res.resp.Body, cancels[res.replica]}
+	}
+
+	for i, cancel := range cancels {
+		if i != lastReplica {
+			cancel()
+		}
+	}
+	if lastResp != nil {
+		return lastResp, nil
+	}
+	return nil, lastErr
+}
+
+// setReplicas replaces the base URLs of the providers in spec, a
+// comma-separated list of name=url pairs where several URLs for one provider
+// are separated by "|", e.g. "openweathermap=http://a.example|http://b.example".
+func setReplicas(spec string) error {
+	pairs, err := parseProviderPairs(spec)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range pairs {
+		var urls []string
+		for _, u := range strings.Split(value, "|") {
+			if u = strings.TrimRight(strings.TrimSpace(u), "/"); u != "" {
+				urls = append(urls, u)
+			}
+		}
+		if len(urls) == 0 {
+			return fmt.Errorf("no URLs given for %s", name)
+		}
+		replicas[name] = urls
+	}
+	return nil
+}
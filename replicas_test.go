@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// replica starts a server that answers with status and body after delay.
+func replica(t *testing.T, status int, body string, delay time.Duration) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHedgedDo(t *testing.T) {
+	tests := []struct {
+		name       string
+		replicas   func(t *testing.T) []string
+		wantStatus int
+		wantBody   string
+		maxTook    time.Duration
+	}{
+		{
+			name: "faster replica wins",
+			replicas: func(t *testing.T) []string {
+				return []string{
+					replica(t, http.StatusOK, "slow", 500*time.Millisecond).URL,
+					replica(t, http.StatusOK, "fast", 0).URL,
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "fast",
+			maxTook:    250 * time.Millisecond,
+		},
+		{
+			name: "server error loses to a slower answer",
+			replicas: func(t *testing.T) []string {
+				return []string{
+					replica(t, http.StatusBadGateway, "broken", 0).URL,
+					replica(t, http.StatusOK, "slower", 50*time.Millisecond).URL,
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "slower",
+		},
+		{
+			name: "rate limit loses to a slower answer",
+			replicas: func(t *testing.T) []string {
+				return []string{
+					replica(t, http.StatusTooManyRequests, "limited", 0).URL,
+					replica(t, http.StatusOK, "slower", 20*time.Millisecond).URL,
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "slower",
+		},
+		{
+			name: "client error is returned when nothing succeeds",
+			replicas: func(t *testing.T) []string {
+				return []string{
+					replica(t, http.StatusTooManyRequests, "limited", 0).URL,
+					replica(t, http.StatusNotFound, "missing", 50*time.Millisecond).URL,
+				}
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   "missing",
+		},
+		{
+			name: "every replica fails",
+			replicas: func(t *testing.T) []string {
+				return []string{
+					replica(t, http.StatusInternalServerError, "first", 0).URL,
+					replica(t, http.StatusServiceUnavailable, "second", 50*time.Millisecond).URL,
+				}
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   "second",
+		},
+		{
+			name: "single replica",
+			replicas: func(t *testing.T) []string {
+				return []string{replica(t, http.StatusOK, "only", 0).URL}
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   "only",
+		},
+	}
+
+	for _, tt := range tests {
+		useReplicas(t, "fake", tt.replicas(t)...)
+
+		begin := time.Now()
+		resp, err := hedgedDo(context.Background(), "fake", upstreamRequest{path: "/"})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		took := time.Since(begin)
+
+		if err != nil || resp.StatusCode != tt.wantStatus || string(body) != tt.wantBody {
+			t.Errorf("%s: got %d %q (%v), want %d %q", tt.name, resp.StatusCode, body, err, tt.wantStatus, tt.wantBody)
+		}
+		if tt.maxTook > 0 && took > tt.maxTook {
+			t.Errorf("%s: took %s waiting for the slow replica", tt.name, took)
+		}
+	}
+}
+
+func TestHedgedDoUnknownProvider(t *testing.T) {
+	if _, err := hedgedDo(context.Background(), "unconfigured", upstreamRequest{path: "/"}); err == nil {
+		t.Error("hedgedDo succeeded for a provider without replicas")
+	}
+}
+
+func TestSetReplicas(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{"openweathermap=http://a.example|http://b.example/", []string{"http://a.example", "http://b.example"}, false},
+		{"openweathermap= http://a.example ", []string{"http://a.example"}, false},
+		{"openweathermap=|", nil, true},
+		{"nws=http://a.example", nil, true},
+	}
+
+	for _, tt := range tests {
+		useReplicas(t, "openweathermap", replicas["openweathermap"]...)
+		err := setReplicas(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("setReplicas(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if err == nil && fmt.Sprint(replicas["openweathermap"]) != fmt.Sprint(tt.want) {
+			t.Errorf("setReplicas(%q) = %v, want %v", tt.spec, replicas["openweathermap"], tt.want)
+		}
+	}
+}
+
+// postProvider is a provider whose API takes its query as a JSON POST, like
+// the ones upstreamRequest's method and body are for.
+type postProvider struct{}
+
+func (postProvider) name() string        { return "post" }
+func (postProvider) attribution() string { return "" }
+func (postProvider) coverage() []string  { return nil }
+
+func (p postProvider) conditions(ctx context.Context, city string) (observation, error) {
+	r, err := jsonRequest("/v1/observations", map[string]interface{}{"city": city, "fields": []string{"temp_k"}})
+	if err != nil {
+		return observation{}, err
+	}
+	resp, err := hedgedDo(ctx, p.name(), r)
+	if err != nil {
+		return observation{}, err
+	}
+	defer resp.Body.Close()
+
+	var d struct {
+		Kelvin float64 `json:"temp_k"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return observation{}, err
+	}
+	return observation{kelvin: d.Kelvin}, nil
+}
+
+func TestHedgedDoPost(t *testing.T) {
+	type received struct {
+		method, path, contentType, body string
+	}
+	got := make(chan received, 2)
+	handler := func(delay time.Duration) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			got <- received{r.Method, r.URL.Path, r.Header.Get("Content-Type"), string(body)}
+			time.Sleep(delay)
+			fmt.Fprint(w, `{"temp_k": 291.5}`)
+		}
+	}
+	// Each replica must get the whole body, not what another left unread.
+	fast := httptest.NewServer(handler(0))
+	defer fast.Close()
+	slow := httptest.NewServer(handler(20 * time.Millisecond))
+	defer slow.Close()
+	useReplicas(t, "post", fast.URL, slow.URL)
+
+	o, err := postProvider{}.conditions(context.Background(), "London")
+	if err != nil || o.kelvin != 291.5 {
+		t.Fatalf("conditions = %v, %v, want 291.5K", o.kelvin, err)
+	}
+
+	want := received{http.MethodPost, "/v1/observations", "application/json", `{"city":"London","fields":["temp_k"]}`}
+	for i := 0; i < 2; i++ {
+		select {
+		case r := <-got:
+			if r != want {
+				t.Errorf("replica received %+v, want %+v", r, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("a replica was never asked")
+		}
+	}
+}
+
+func TestUpstreamRequestBuild(t *testing.T) {
+	tests := []struct {
+		r           upstreamRequest
+		wantMethod  string
+		wantType    string
+		wantHasBody bool
+	}{
+		{upstreamRequest{path: "/weather"}, http.MethodGet, "", false},
+		{upstreamRequest{method: http.MethodPost, path: "/weather", contentType: "application/json", body: []byte("{}")}, http.MethodPost, "application/json", true},
+	}
+
+	for _, tt := range tests {
+		req, err := tt.r.build(context.Background(), "http://api.example")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Method != tt.wantMethod || req.URL.String() != "http://api.example/weather" ||
+			req.Header.Get("Content-Type") != tt.wantType || (req.Body != nil) != tt.wantHasBody {
+			t.Errorf("build(%+v) = %s %s with type %q and body %v", tt.r, req.Method, req.URL, req.Header.Get("Content-Type"), req.Body != nil)
+		}
+	}
+}
+
+func TestRateLimitedReplicaDoesNotCoolDown(t *testing.T) {
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer limited.Close()
+	healthy := replica(t, http.StatusOK, `{"main": {"temp": 285.15}}`, 20*time.Millisecond)
+	useReplicas(t, "openweathermap", limited.URL, healthy.URL)
+	defer func(c *cooldownList) { cooldowns = c }(cooldowns)
+	cooldowns = &cooldownList{until: map[string]time.Time{}}
+
+	obs, err := multiWeatherProvider{openWeatherMap{}}.readings(context.Background(), "Paris")
+	if err != nil || len(obs) != 1 || obs[0].kelvin != 285.15 {
+		t.Errorf("readings = %v, %v, want the healthy replica's 285.15K", obs, err)
+	}
+	if until, ok := cooldowns.active("openweathermap"); ok {
+		t.Errorf("one replica's rate limit cooled the provider down until %s", until)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import "math"
+
+// scoreConfig sets the ideal conditions and the weight of each component of
+// the weather score.
+type scoreConfig struct {
+	idealMinC, idealMaxC float64 // ideal temperature range in Celsius
+	toleranceC           float64 // degrees outside the range at which the temperature scores 0
+	calmWind, galeWind   float64 // wind in m/s that scores 1 and 0
+
+	tempWeight, precipWeight, windWeight, cloudWeight float64
+}
+
+var scoring = scoreConfig{
+	idealMinC:    20,
+	idealMaxC:    24,
+	toleranceC:   10,
+	calmWind:     5,
+	galeWind:     20,
+	tempWeight:   0.4,
+	precipWeight: 0.3,
+	windWeight:   0.15,
+	cloudWeight:  0.15,
+}
+
+// scoreInputs are the conditions a weather score is computed from. Nil
+// inputs are left out of the score and the remaining weights rescaled.
+type scoreInputs struct {
+	tempC        float64
+	precipChance *float64 // percent
+	windSpeed    *float64 // m/s
+	cloudCover   *float64 // percent
+}
+
+// weatherScore rates the conditions from 0 (awful) to 100 (ideal). It also
+// returns the points each component contributed; they sum to the score.
+//
+// A gale (wind at or above galeWind) caps the score at 0 rather than only
+// losing the wind's share, since no temperature makes a storm pleasant; the
+// components are scaled down with it. Precipitation only costs its own
+// weight, so -score-weight-precip decides how much rain spoils the score.
+func weatherScore(in scoreInputs, cfg scoreConfig) (float64, map[string]float64) {
+	type component struct {
+		name   string
+		weight float64
+		score  float64
+	}
+
+	components := []component{{"temp", cfg.tempWeight, tempScore(in.tempC, cfg)}}
+	if in.precipChance != nil {
+		components = append(components, component{"precipitation", cfg.precipWeight, 1 - clamp(*in.precipChance/100)})
+	}
+	if in.windSpeed != nil {
+		components = append(components, component{"wind", cfg.windWeight,
+			1 - clamp((*in.windSpeed-cfg.calmWind)/(cfg.galeWind-cfg.calmWind))})
+	}
+	if in.cloudCover != nil {
+		components = append(components, component{"cloud", cfg.cloudWeight, 1 - clamp(*in.cloudCover/100)})
+	}
+
+	total := 0.0
+	for _, c := range components {
+		total += c.weight
+	}
+
+	score := 0.0
+	contributions := map[string]float64{}
+	for _, c := range components {
+		points := 0.0
+		if total > 0 {
+			points = 100 * c.weight * c.score / total
+		}
+		contributions[c.name] = points
+		score += points
+	}
+
+	if in.windSpeed != nil && *in.windSpeed >= cfg.galeWind {
+		for name := range contributions {
+			contributions[name] = 0
+		}
+		score = 0
+	}
+	return score, contributions
+}
+
+// tempScore is 1 within the ideal range, falling linearly to 0 at the
+// tolerance outside it.
+func tempScore(c float64, cfg scoreConfig) float64 {
+	off := math.Max(cfg.idealMinC-c, c-cfg.idealMaxC)
+	if off <= 0 {
+		return 1
+	}
+	return 1 - clamp(off/cfg.toleranceC)
+}
+
+// clamp limits v to the range 0 to 1.
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWeatherScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        scoreInputs
+		wantScore float64
+	}{
+		{"perfect", scoreInputs{tempC: 22, precipChance: ptr(0), windSpeed: ptr(2), cloudCover: ptr(0)}, 100},
+		{"perfect, temperature only", scoreInputs{tempC: 22}, 100},
+		{"storm", scoreInputs{tempC: 22, precipChance: ptr(100), windSpeed: ptr(25), cloudCover: ptr(100)}, 0},
+		// Rain only costs the precipitation weight; a gale caps the score.
+		{"warm rain", scoreInputs{tempC: 22, precipChance: ptr(100), windSpeed: ptr(2), cloudCover: ptr(100)}, 55},
+		{"cold rain", scoreInputs{tempC: 10, precipChance: ptr(100), windSpeed: ptr(2), cloudCover: ptr(100)}, 15},
+		{"gale", scoreInputs{tempC: 22, precipChance: ptr(0), windSpeed: ptr(20), cloudCover: ptr(0)}, 0},
+		{"showers likely", scoreInputs{tempC: 22, precipChance: ptr(60), windSpeed: ptr(2), cloudCover: ptr(0)}, 82},
+		{"overcast", scoreInputs{tempC: 22, precipChance: ptr(0), windSpeed: ptr(2), cloudCover: ptr(100)}, 85},
+		{"cold", scoreInputs{tempC: 10, precipChance: ptr(0), windSpeed: ptr(2), cloudCover: ptr(0)}, 60},
+		{"freezing", scoreInputs{tempC: -5}, 0},
+		{"breezy", scoreInputs{tempC: 22, windSpeed: ptr(12.5)}, 100 * (0.4 + 0.15*0.5) / 0.55},
+	}
+
+	for _, tt := range tests {
+		score, components := weatherScore(tt.in, scoring)
+		if math.Abs(score-tt.wantScore) > 1e-9 {
+			t.Errorf("%s: score = %v, want %v", tt.name, score, tt.wantScore)
+		}
+		sum := 0.0
+		for _, points := range components {
+			sum += points
+		}
+		if math.Abs(sum-score) > 1e-9 {
+			t.Errorf("%s: components %v sum to %v, not the score %v", tt.name, components, sum, score)
+		}
+	}
+}
+
+func TestWeatherScorePrecipitationWeight(t *testing.T) {
+	rain := scoreInputs{tempC: 22, precipChance: ptr(100), windSpeed: ptr(2), cloudCover: ptr(100)}
+	dry := scoreInputs{tempC: 22, precipChance: ptr(0), windSpeed: ptr(2), cloudCover: ptr(100)}
+
+	tests := []struct {
+		precipWeight      float64
+		wantRain, wantDry float64
+	}{
+		{0, 100 * 0.55 / 0.7, 100 * 0.55 / 0.7},
+		{0.3, 55, 85},
+		{0.9, 100 * 0.55 / 1.6, 100 * 1.45 / 1.6},
+	}
+
+	for _, tt := range tests {
+		cfg := scoring
+		cfg.precipWeight = tt.precipWeight
+		gotRain, _ := weatherScore(rain, cfg)
+		gotDry, _ := weatherScore(dry, cfg)
+		if math.Abs(gotRain-tt.wantRain) > 1e-9 || math.Abs(gotDry-tt.wantDry) > 1e-9 {
+			t.Errorf("precipitation weight %v: rain scores %v and dry %v, want %v and %v",
+				tt.precipWeight, gotRain, gotDry, tt.wantRain, tt.wantDry)
+		}
+	}
+}
+
+func TestTempScore(t *testing.T) {
+	tests := []struct {
+		c    float64
+		want float64
+	}{
+		{20, 1},
+		{24, 1},
+		{15, 0.5},
+		{29, 0.5},
+		{10, 0},
+		{40, 0},
+	}
+
+	for _, tt := range tests {
+		if got := tempScore(tt.c, scoring); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("tempScore(%v) = %v, want %v", tt.c, got, tt.want)
+		}
+	}
+}
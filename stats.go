@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// stats holds the service counters reported by /stats. Fields are only
+// accessed through sync/atomic.
+var stats struct {
+	requests       int64
+	failures       int64
+	providerErrors int64
+	totalNanos     int64
+}
+
+// recordRequest counts a completed weather request and its duration.
+func recordRequest(took time.Duration, failed bool) {
+	atomic.AddInt64(&stats.requests, 1)
+	atomic.AddInt64(&stats.totalNanos, int64(took))
+	if failed {
+		atomic.AddInt64(&stats.failures, 1)
+	}
+}
+
+// resetStats zeroes every counter. Requests in flight while the reset runs
+// are counted against the fresh totals.
+func resetStats() {
+	atomic.StoreInt64(&stats.requests, 0)
+	atomic.StoreInt64(&stats.failures, 0)
+	atomic.StoreInt64(&stats.providerErrors, 0)
+	atomic.StoreInt64(&stats.totalNanos, 0)
+}
+
+// statsHandler writes the current counters as JSON.
+func statsHandler(writer http.ResponseWriter, req *http.Request) {
+	requests := atomic.LoadInt64(&stats.requests)
+	mean := time.Duration(0)
+	if requests > 0 {
+		mean = time.Duration(atomic.LoadInt64(&stats.totalNanos) / requests)
+	}
+
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"requests":        requests,
+		"failures":        atomic.LoadInt64(&stats.failures),
+		"provider_errors": atomic.LoadInt64(&stats.providerErrors),
+		"mean_took":       mean.String(),
+	})
+}
+
+// statsReset zeroes the counters. It only accepts POST requests made from
+// the local machine.
+func statsReset(writer http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(writer, errMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !isLocal(req) {
+		writeError(writer, errForbidden, "forbidden")
+		return
+	}
+
+	resetStats()
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// isLocal reports whether the request was made from a loopback address.
+// X-Forwarded-For is deliberately ignored since clients can set it.
+func isLocal(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStatsReset(t *testing.T) {
+	tests := []struct {
+		method, remote string
+		wantStatus     int
+		wantReset      bool
+	}{
+		{http.MethodPost, "127.0.0.1:5000", http.StatusNoContent, true},
+		{http.MethodPost, "[::1]:5000", http.StatusNoContent, true},
+		{http.MethodGet, "127.0.0.1:5000", http.StatusMethodNotAllowed, false},
+		{http.MethodPost, "203.0.113.9:5000", http.StatusForbidden, false},
+	}
+
+	for _, tt := range tests {
+		recordRequest(time.Second, true)
+		atomic.AddInt64(&stats.providerErrors, 1)
+
+		req := httptest.NewRequest(tt.method, "/stats/reset", nil)
+		req.RemoteAddr = tt.remote
+		// Forwarding headers must not make a remote client local.
+		req.Header.Set("X-Forwarded-For", "127.0.0.1")
+		rec := httptest.NewRecorder()
+		statsReset(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s from %s: status = %d, want %d", tt.method, tt.remote, rec.Code, tt.wantStatus)
+		}
+		zero := atomic.LoadInt64(&stats.requests) == 0 &&
+			atomic.LoadInt64(&stats.failures) == 0 &&
+			atomic.LoadInt64(&stats.providerErrors) == 0 &&
+			atomic.LoadInt64(&stats.totalNanos) == 0
+		if zero != tt.wantReset {
+			t.Errorf("%s from %s: counters zeroed = %v, want %v", tt.method, tt.remote, zero, tt.wantReset)
+		}
+	}
+}
+
+func TestResetStatsUnderLoad(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				recordRequest(time.Millisecond, j%2 == 0)
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		resetStats()
+	}
+	wg.Wait()
+
+	resetStats()
+	if n := atomic.LoadInt64(&stats.requests); n != 0 {
+		t.Errorf("requests = %d after reset, want 0", n)
+	}
+}
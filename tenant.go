@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// keyedProviders builds a provider that uses a client-supplied API key, for
+// each provider that takes one.
+var keyedProviders = map[string]func(key string) weatherProvider{
+	"weatherunderground": func(key string) weatherProvider { return weatherUnderground{key: key} },
+}
+
+// parseClientKey parses an X-Provider-Key header of the form name=key.
+// The key is checked to be a plain token so it can be placed in a URL.
+func parseClientKey(header string) (string, string, error) {
+	kv := strings.SplitN(header, "=", 2)
+	if len(kv) != 2 {
+		return "", "", fmt.Errorf("X-Provider-Key must be of the form provider=key")
+	}
+
+	name, key := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+	if _, ok := keyedProviders[name]; !ok {
+		return "", "", fmt.Errorf("%q does not accept an API key", name)
+	}
+	if key == "" || strings.IndexFunc(key, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	}) >= 0 {
+		return "", "", fmt.Errorf("the API key for %s is malformed", name)
+	}
+	return name, key, nil
+}
+
+// clientKeyed marks a provider using a client's own key. Its readings,
+// failures and cool-downs are the client's, so readings keeps them out of the
+// shared cache, cool-downs and last-success times. The server's throttle
+// (-min-interval) protects the server's quota, so it does not apply either.
+type clientKeyed struct {
+	weatherProvider
+}
+
+// isClientKeyed reports whether p uses a client's own key.
+func isClientKeyed(p weatherProvider) bool {
+	_, ok := p.(clientKeyed)
+	return ok
+}
+
+// withClientKey returns a copy of the groups in which the named provider
+// uses key. The key is only held by the copy, which lives for one request.
+func (g providerGroups) withClientKey(name, key string) providerGroups {
+	keyed := clientKeyed{keyedProviders[name](key)}
+
+	groups := make(providerGroups, len(g))
+	for i, group := range g {
+		groups[i] = make(multiWeatherProvider, len(group))
+		for j, p := range group {
+			if p.name() == name {
+				p = keyed
+			}
+			groups[i][j] = p
+		}
+	}
+	return groups
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseClientKey(t *testing.T) {
+	tests := []struct {
+		header   string
+		wantName string
+		wantKey  string
+		wantErr  bool
+	}{
+		{"weatherunderground=abc123", "weatherunderground", "abc123", false},
+		{" weatherunderground = a-b_C9 ", "weatherunderground", "a-b_C9", false},
+		{"weatherunderground", "", "", true},
+		{"weatherunderground=", "", "", true},
+		{"weatherunderground=abc/../123", "", "", true},
+		{"weatherunderground=abc?x=1", "", "", true},
+		{"openweathermap=abc123", "", "", true},
+	}
+
+	for _, tt := range tests {
+		name, key, err := parseClientKey(tt.header)
+		if (err != nil) != tt.wantErr || name != tt.wantName || key != tt.wantKey {
+			t.Errorf("parseClientKey(%q) = %q, %q, %v, want %q, %q, error %v", tt.header, name, key, err, tt.wantName, tt.wantKey, tt.wantErr)
+		}
+	}
+}
+
+func TestWeatherClientKey(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	limited := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if limited && strings.Contains(r.URL.Path, "/tenantkey/") {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"current_observation": {"temp_c": 12}}`)
+	}))
+	defer server.Close()
+
+	useReplicas(t, "weatherunderground", server.URL)
+	useGroups(t, multiWeatherProvider{weatherUnderground{}})
+	useCacheTTL(t, time.Hour)
+	defer func(k string, s *successLog, c *cooldownList) { wuKey, lastSuccess, cooldowns = k, s, c }(wuKey, lastSuccess, cooldowns)
+	wuKey = "serverkey"
+	lastSuccess = &successLog{at: map[string]time.Time{}}
+	cooldowns = &cooldownList{until: map[string]time.Time{}}
+
+	withKey := http.Header{"X-Provider-Key": {"weatherunderground=tenantkey"}}
+	tests := []struct {
+		name       string
+		header     http.Header
+		limited    bool
+		clearCache bool
+		wantPath   string // "" when the answer should come from the cache
+		wantCode   int
+	}{
+		{"client key", withKey, false, false, "/api/tenantkey/conditions/q/Leeds.json", http.StatusOK},
+		// The client's answer was not cached for everyone else.
+		{"server key", nil, false, false, "/api/serverkey/conditions/q/Leeds.json", http.StatusOK},
+		{"server key cached", nil, false, false, "", http.StatusOK},
+		// Nor is the server's cached answer given to the client.
+		{"client key again", withKey, false, false, "/api/tenantkey/conditions/q/Leeds.json", http.StatusOK},
+		{"client key limited", withKey, true, false, "/api/tenantkey/conditions/q/Leeds.json", errUpstream.Status},
+		// The client's cool-down does not hold up the server's key.
+		{"server key after limit", nil, false, true, "/api/serverkey/conditions/q/Leeds.json", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		mu.Lock()
+		paths, limited = nil, tt.limited
+		mu.Unlock()
+		if tt.clearCache {
+			cache.entries = map[string]cacheEntry{}
+		}
+
+		rec, _ := getWeather(t, "/weather/Leeds", tt.header)
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.wantCode, rec.Body)
+		}
+		mu.Lock()
+		got := strings.Join(paths, ",")
+		mu.Unlock()
+		if got != tt.wantPath {
+			t.Errorf("%s: asked for %q, want %q", tt.name, got, tt.wantPath)
+		}
+		if strings.Contains(rec.Body.String(), "tenantkey") {
+			t.Errorf("%s: the client's key is in the response: %s", tt.name, rec.Body)
+		}
+		if tt.name != "client key" {
+			continue
+		}
+		if _, ok := cache.get("weatherunderground", "Leeds"); ok {
+			t.Errorf("%s: the client's answer was cached", tt.name)
+		}
+		if _, ok := lastSuccess.get("weatherunderground"); ok {
+			t.Errorf("%s: the client's answer was recorded as the provider's last success", tt.name)
+		}
+	}
+
+	if _, ok := cooldowns.active("weatherunderground"); ok {
+		t.Error("the client's rate limit put the provider into a cool-down")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttleMaxWait is the longest a call waits for its throttle slot before
+// failing. Zero waits as long as it takes.
+var throttleMaxWait = 10 * time.Second
+
+// throttledProvider spaces calls to the wrapped provider at least interval
+// apart. Calls made sooner wait for their turn, unless it is further off
+// than throttleMaxWait.
+type throttledProvider struct {
+	weatherProvider
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func (t *throttledProvider) conditions(ctx context.Context, city string) (observation, error) {
+	var giveUp <-chan time.Time
+	if throttleMaxWait > 0 {
+		timer := time.NewTimer(throttleMaxWait)
+		defer timer.Stop()
+		giveUp = timer.C
+	}
+
+	for {
+		// The next slot is counted from when the call is actually made,
+		// since a sleep can overrun, so a woken caller checks again rather
+		// than trusting its turn has come.
+		t.mu.Lock()
+		wait := time.Until(t.next)
+		if wait <= 0 {
+			// Another caller may have fetched the city while this one
+			// waited. Its reading is used without spending the slot.
+			if o, ok := cache.get(t.name(), city); ok {
+				t.mu.Unlock()
+				return o, nil
+			}
+			t.next = time.Now().Add(t.interval)
+			t.mu.Unlock()
+			return t.weatherProvider.conditions(ctx, city)
+		}
+		t.mu.Unlock()
+
+		logf(ctx, "Throttling %s for %s\n", t.name(), wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return observation{}, ctx.Err()
+		case <-requestDone(ctx):
+			return observation{}, fmt.Errorf("gave up waiting to call %s: the request ended", t.name())
+		case <-giveUp:
+			return observation{}, fmt.Errorf("gave up waiting to call %s after %s", t.name(), throttleMaxWait)
+		}
+	}
+}
+
+type requestDoneKey struct{}
+
+// withRequestDone returns ctx carrying done, the Done channel of the request
+// a detached provider call is made for. The call itself outlives the
+// request, but a throttled call still waiting for its slot when the request
+// ends is not worth spending quota on.
+func withRequestDone(ctx context.Context, done <-chan struct{}) context.Context {
+	return context.WithValue(ctx, requestDoneKey{}, done)
+}
+
+// requestDone returns the Done channel carried by ctx, or nil, which never
+// fires, if there is none.
+func requestDone(ctx context.Context) <-chan struct{} {
+	done, _ := ctx.Value(requestDoneKey{}).(<-chan struct{})
+	return done
+}
+
+// throttleProviders wraps the named providers in the registry according to
+// spec, a comma-separated list of name=interval pairs such as
+// "openweathermap=1s,weatherunderground=2s".
+func throttleProviders(spec string) error {
+	pairs, err := parseProviderPairs(spec)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range pairs {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid interval for %s: %v", name, err)
+		}
+		providers[name] = &throttledProvider{weatherProvider: providers[name], interval: interval}
+	}
+	return nil
+}
+
+// parseProviderPairs parses a comma-separated list of name=value pairs,
+// checking that each name is a known provider.
+func parseProviderPairs(spec string) (map[string]string, error) {
+	pairs := map[string]string{}
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid provider setting %q", pair)
+		}
+
+		name := strings.TrimSpace(kv[0])
+		if _, ok := providers[name]; !ok {
+			return nil, fmt.Errorf("unknown weather provider %q", name)
+		}
+		pairs[name] = strings.TrimSpace(kv[1])
+	}
+	return pairs, nil
+}
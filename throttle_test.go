@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottledProviderSpacesCalls(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		calls    int
+	}{
+		{0, 3},
+		{20 * time.Millisecond, 1},
+		{20 * time.Millisecond, 5},
+		{50 * time.Millisecond, 3},
+	}
+
+	for _, tt := range tests {
+		fake := reading("fake", 290)
+		p := &throttledProvider{weatherProvider: fake, interval: tt.interval}
+
+		// Concurrent callers queue for their slot.
+		var wg sync.WaitGroup
+		for i := 0; i < tt.calls; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := p.conditions(context.Background(), "Paris"); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(fake.times) != tt.calls {
+			t.Fatalf("interval %s: %d calls reached the provider, want %d", tt.interval, len(fake.times), tt.calls)
+		}
+		sort.Slice(fake.times, func(i, j int) bool { return fake.times[i].Before(fake.times[j]) })
+		for i := 1; i < len(fake.times); i++ {
+			if gap := fake.times[i].Sub(fake.times[i-1]); gap < tt.interval {
+				t.Errorf("interval %s: calls %d and %d were %s apart", tt.interval, i, i+1, gap)
+			}
+		}
+	}
+}
+
+func TestThrottleProviders(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"", false},
+		{"openweathermap=1s", false},
+		{"openweathermap=1s, weatherunderground=250ms", false},
+		{"openweathermap", true},
+		{"openweathermap=soon", true},
+		{"nws=1s", true},
+	}
+
+	for _, tt := range tests {
+		saved := map[string]weatherProvider{}
+		for name, p := range providers {
+			saved[name] = p
+		}
+
+		err := throttleProviders(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("throttleProviders(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+		}
+		if err == nil && tt.spec != "" {
+			if _, ok := providers["openweathermap"].(*throttledProvider); !ok {
+				t.Errorf("throttleProviders(%q) did not wrap openweathermap", tt.spec)
+			}
+		}
+
+		providers = saved
+	}
+}
+
+func TestThrottledProviderGivesUp(t *testing.T) {
+	defer func(w time.Duration) { throttleMaxWait = w }(throttleMaxWait)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	ended := make(chan struct{})
+	close(ended)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		maxWait time.Duration
+	}{
+		{"context cancelled", cancelled, 0},
+		{"request ended", withRequestDone(context.Background(), ended), 0},
+		{"wait too long", context.Background(), 20 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		throttleMaxWait = tt.maxWait
+		fake := reading("fake", 290)
+		p := &throttledProvider{weatherProvider: fake, interval: time.Hour, next: time.Now().Add(time.Hour)}
+
+		begin := time.Now()
+		if _, err := p.conditions(tt.ctx, "Paris"); err == nil {
+			t.Errorf("%s: the call waited for its slot, want an error", tt.name)
+		}
+		if took := time.Since(begin); took > time.Second {
+			t.Errorf("%s: gave up after %s", tt.name, took)
+		}
+		if fake.calls != 0 {
+			t.Errorf("%s: the provider was called %d times", tt.name, fake.calls)
+		}
+	}
+}
+
+func TestThrottledReadingsShareTheCache(t *testing.T) {
+	useCacheTTL(t, time.Hour)
+	fake := reading("fake", 290)
+	group := multiWeatherProvider{&throttledProvider{weatherProvider: fake, interval: 20 * time.Millisecond}}
+
+	// Every request misses the cache and queues, but only the first to get
+	// a slot calls the provider; the rest find its reading cached.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			obs, err := group.readings(context.Background(), "Paris")
+			if err != nil || len(obs) != 1 || obs[0].kelvin != 290 {
+				t.Errorf("readings = %v, %v, want 290K", obs, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if fake.calls != 1 {
+		t.Errorf("the provider was called %d times, want once", fake.calls)
+	}
+}
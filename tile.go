@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTilePoints caps how many grid points one /tile request may sample.
+var maxTilePoints = 100
+
+// coordinatePrecision is the number of decimal places coordinates are
+// rounded to when caching their readings, so that nearby points share an
+// entry. Two places is about a kilometre.
+var coordinatePrecision = 2
+
+// tileGrid returns the points on a grid of the given step, in degrees,
+// covering the bounding box from its south-west corner. It returns an error
+// when the box is invalid or would need more than max points.
+func tileGrid(minLat, minLon, maxLat, maxLon, step float64, max int) ([]location, error) {
+	// The comparisons are written so that NaN fails them.
+	if !(step > 0) || math.IsInf(step, 0) {
+		return nil, errors.New("step must be a positive number of degrees")
+	}
+	if !(minLat >= -90 && maxLat <= 90 && minLat <= maxLat) {
+		return nil, errors.New("latitudes must be between -90 and 90, with minlat at most maxlat")
+	}
+	if !(minLon >= -180 && maxLon <= 180 && minLon <= maxLon) {
+		return nil, errors.New("longitudes must be between -180 and 180, with minlon at most maxlon")
+	}
+
+	// The small epsilon keeps floating point error from dropping the last
+	// row or column when the box is an exact multiple of step. The counts
+	// are checked as floats, since a tiny step would overflow an int.
+	rows := math.Floor((maxLat-minLat)/step+1e-9) + 1
+	cols := math.Floor((maxLon-minLon)/step+1e-9) + 1
+	if rows*cols > float64(max) {
+		return nil, fmt.Errorf("the grid has %.0f points, at most %d are allowed", rows*cols, max)
+	}
+
+	points := make([]location, 0, int(rows*cols))
+	for r := 0; r < int(rows); r++ {
+		for c := 0; c < int(cols); c++ {
+			points = append(points, location{
+				Latitude:  minLat + float64(r)*step,
+				Longitude: minLon + float64(c)*step,
+			})
+		}
+	}
+	return points, nil
+}
+
+// coordinateKey returns the cache key of l, its coordinates rounded to
+// coordinatePrecision.
+func coordinateKey(l location) string {
+	return roundCoordinate(l.Latitude) + "," + roundCoordinate(l.Longitude)
+}
+
+// roundCoordinate formats v rounded to coordinatePrecision decimal places.
+func roundCoordinate(v float64) string {
+	scale := math.Pow(10, float64(coordinatePrecision))
+	// Adding zero turns -0 into 0, so points either side of the equator or
+	// meridian share a key.
+	return strconv.FormatFloat(math.Round(v*scale)/scale+0, 'f', coordinatePrecision, 64)
+}
+
+// current returns the current temperature in Kelvin at each of the points.
+// Points near enough to share a cached reading use it, and the rest are
+// fetched from Open-Meteo in a single request.
+func (o openMeteo) current(ctx context.Context, points []location) ([]float64, error) {
+	kelvins := make([]float64, len(points))
+	var missing []int
+	for i, p := range points {
+		if cached, ok := cache.get("openmeteo", coordinateKey(p)); ok {
+			kelvins[i] = cached.kelvin
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		logf(ctx, "Open-Meteo readings for all %d points were cached\n", len(points))
+		return kelvins, nil
+	}
+
+	lats := make([]string, len(missing))
+	lons := make([]string, len(missing))
+	for j, i := range missing {
+		lats[j] = strconv.FormatFloat(points[i].Latitude, 'f', 4, 64)
+		lons[j] = strconv.FormatFloat(points[i].Longitude, 'f', 4, 64)
+	}
+
+	resp, err := fetch(ctx, openMeteoForecastURL+"/v1/forecast?current=temperature_2m&latitude="+
+		strings.Join(lats, ",")+"&longitude="+strings.Join(lons, ","))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if err := checkOpenMeteo(resp); err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		Current *struct {
+			Celsius *float64 `json:"temperature_2m"`
+		} `json:"current"`
+	}
+
+	// Open-Meteo returns a bare object for one location and an array for
+	// several.
+	var results []result
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		err = json.Unmarshal(body, &results)
+	} else {
+		results = make([]result, 1)
+		err = json.Unmarshal(body, &results[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) != len(missing) {
+		return nil, fmt.Errorf("Open-Meteo returned %d readings for %d points", len(results), len(missing))
+	}
+
+	// Check every reading before caching any, so a partial answer caches
+	// nothing.
+	for j, i := range missing {
+		if results[j].Current == nil || results[j].Current.Celsius == nil {
+			return nil, fmt.Errorf("Open-Meteo returned no current temperature for %.4f,%.4f", points[i].Latitude, points[i].Longitude)
+		}
+	}
+
+	now := time.Now()
+	for j, i := range missing {
+		kelvins[i] = celsiusToKelvin(*results[j].Current.Celsius)
+		cache.set("openmeteo", coordinateKey(points[i]), observation{provider: "openmeteo", kelvin: kelvins[i], fetched: now})
+	}
+
+	logf(ctx, "Open-Meteo responded with %d current readings, %d were cached\n", len(missing), len(points)-len(missing))
+
+	return kelvins, nil
+}
+
+// tile is the http handler for /tile. It samples the current temperature on
+// a grid covering the given bounding box.
+func tile(writer http.ResponseWriter, req *http.Request) {
+	begin := time.Now()
+	query := req.URL.Query()
+
+	var bounds [5]float64
+	for i, name := range []string{"minlat", "minlon", "maxlat", "maxlon", "step"} {
+		v, err := strconv.ParseFloat(query.Get(name), 64)
+		if err != nil {
+			writeError(writer, errInvalidParameter, name+" must be a number")
+			return
+		}
+		bounds[i] = v
+	}
+
+	units, err := requestUnits(req)
+	if err != nil {
+		writeError(writer, errInvalidParameter, err.Error())
+		return
+	}
+
+	points, err := tileGrid(bounds[0], bounds[1], bounds[2], bounds[3], bounds[4], maxTilePoints)
+	if err != nil {
+		writeError(writer, errInvalidParameter, err.Error())
+		return
+	}
+
+	kelvins, err := om.current(req.Context(), points)
+	if err != nil {
+		writeError(writer, errUpstream, err.Error())
+		return
+	}
+
+	grid := make([]map[string]interface{}, len(points))
+	for i, p := range points {
+		grid[i] = map[string]interface{}{
+			"lat":  p.Latitude,
+			"lon":  p.Longitude,
+			"temp": fromKelvin(kelvins[i], units),
+		}
+	}
+
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(writer).Encode(map[string]interface{}{
+		"units":       units,
+		"points":      grid,
+		"attribution": []string{openMeteoAttribution},
+		"took":        formatTook(req, time.Since(begin)),
+	})
+}
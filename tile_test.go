@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTileGrid(t *testing.T) {
+	tests := []struct {
+		name                           string
+		minLat, minLon, maxLat, maxLon float64
+		step                           float64
+		want                           int // -1 for an error
+	}{
+		{"single point", 51.5, -0.1, 51.5, -0.1, 0.1, 1},
+		{"exact multiple", 51, 0, 51.5, 0.5, 0.1, 36},
+		{"partial last row", 51, 0, 51.25, 0.1, 0.1, 6},
+		{"at the limit", 0, 0, 0.9, 0.9, 0.1, 100},
+		{"too many points", 0, 0, 1, 1, 0.1, -1},
+		{"whole world", -90, -180, 90, 180, 30, 91},
+		{"tiny step", 0, 0, 1, 1, 1e-20, -1},
+		{"zero step", 0, 0, 1, 1, 0, -1},
+		{"negative step", 0, 0, 1, 1, -0.1, -1},
+		{"NaN step", 0, 0, 1, 1, math.NaN(), -1},
+		{"infinite step", 0, 0, 1, 1, math.Inf(1), -1},
+		{"NaN latitude", math.NaN(), 0, 1, 1, 0.1, -1},
+		{"NaN longitude", 0, 0, 1, math.NaN(), 0.1, -1},
+		{"latitude out of range", 89, 0, 91, 1, 1, -1},
+		{"longitude out of range", 0, -181, 1, -179, 1, -1},
+		{"inverted box", 1, 0, 0, 1, 0.1, -1},
+	}
+
+	for _, tt := range tests {
+		points, err := tileGrid(tt.minLat, tt.minLon, tt.maxLat, tt.maxLon, tt.step, 100)
+		if tt.want < 0 {
+			if err == nil {
+				t.Errorf("%s: got %d points, want an error", tt.name, len(points))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(points) != tt.want {
+			t.Errorf("%s: got %d points, want %d", tt.name, len(points), tt.want)
+		}
+		if first := points[0]; first.Latitude != tt.minLat || first.Longitude != tt.minLon {
+			t.Errorf("%s: first point is %v, want the south-west corner", tt.name, first)
+		}
+	}
+}
+
+func TestCurrentSharesNearbyReadings(t *testing.T) {
+	useCacheTTL(t, time.Minute)
+
+	var requests, points int64
+	useForecastAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		n := len(strings.Split(r.URL.Query().Get("latitude"), ","))
+		atomic.AddInt64(&points, int64(n))
+		if n == 1 {
+			fmt.Fprint(w, `{"current": {"temperature_2m": 10}}`)
+			return
+		}
+		fmt.Fprint(w, "["+strings.TrimSuffix(strings.Repeat(`{"current": {"temperature_2m": 10}},`, n), ",")+"]")
+	})
+
+	steps := []struct {
+		points       []location
+		wantRequests int64
+		wantPoints   int64
+	}{
+		{[]location{{Latitude: 48.8566, Longitude: 2.3522}, {Latitude: 51.5074, Longitude: -0.1278}}, 1, 2},
+		// Within a hundredth of a degree of the first two.
+		{[]location{{Latitude: 51.5075, Longitude: -0.1279}, {Latitude: 48.8571, Longitude: 2.3518}}, 1, 2},
+		// Only the new point is fetched.
+		{[]location{{Latitude: 51.5074, Longitude: -0.1278}, {Latitude: 40.7128, Longitude: -74.006}}, 2, 3},
+	}
+
+	for i, step := range steps {
+		kelvins, err := om.current(context.Background(), step.points)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range kelvins {
+			if k != celsiusToKelvin(10) {
+				t.Errorf("step %d: got %vK, want %vK", i+1, k, celsiusToKelvin(10))
+			}
+		}
+		if requests != step.wantRequests || points != step.wantPoints {
+			t.Errorf("step %d: %d requests for %d points so far, want %d for %d",
+				i+1, requests, points, step.wantRequests, step.wantPoints)
+		}
+	}
+}
+
+func TestCoordinateKey(t *testing.T) {
+	defer func(p int) { coordinatePrecision = p }(coordinatePrecision)
+
+	tests := []struct {
+		precision int
+		a, b      location
+		same      bool
+	}{
+		{2, location{Latitude: 51.5074, Longitude: -0.1278}, location{Latitude: 51.5075, Longitude: -0.1279}, true},
+		{2, location{Latitude: 51.5074, Longitude: -0.1278}, location{Latitude: 51.52, Longitude: -0.1278}, false},
+		{4, location{Latitude: 51.5074, Longitude: -0.1278}, location{Latitude: 51.5075, Longitude: -0.1278}, false},
+		{0, location{Latitude: 51.2, Longitude: -0.4}, location{Latitude: 50.8, Longitude: 0.4}, true},
+	}
+
+	for _, tt := range tests {
+		coordinatePrecision = tt.precision
+		if same := coordinateKey(tt.a) == coordinateKey(tt.b); same != tt.same {
+			t.Errorf("precision %d: %s and %s share a key = %v, want %v",
+				tt.precision, coordinateKey(tt.a), coordinateKey(tt.b), same, tt.same)
+		}
+	}
+}
+
+func TestCurrentRejectsBadAnswers(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		points int
+	}{
+		{"rate limited", http.StatusTooManyRequests, `{"error": true, "reason": "Too many requests"}`, 1},
+		{"server error", http.StatusInternalServerError, `{}`, 2},
+		{"error body with 200", http.StatusOK, `{"error": true, "reason": "Latitude must be in range"}`, 1},
+		{"no temperature", http.StatusOK, `{"current": {"time": "2026-10-14T12:00"}}`, 1},
+		{"null temperature", http.StatusOK, `{"current": {"temperature_2m": null}}`, 1},
+		{"one of two missing", http.StatusOK, `[{"current": {"temperature_2m": 10}}, {}]`, 2},
+	}
+
+	for _, tt := range tests {
+		useCacheTTL(t, time.Hour)
+		useForecastAPI(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		})
+
+		points := []location{{Latitude: 51.5074, Longitude: -0.1278}, {Latitude: 48.8566, Longitude: 2.3522}}[:tt.points]
+		if kelvins, err := om.current(context.Background(), points); err == nil {
+			t.Errorf("%s: current = %v, want an error", tt.name, kelvins)
+		}
+		for _, p := range points {
+			if o, ok := cache.get("openmeteo", coordinateKey(p)); ok {
+				t.Errorf("%s: cached %vK for %s", tt.name, o.kelvin, coordinateKey(p))
+			}
+		}
+	}
+}
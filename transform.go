@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// transforms holds per-provider adjustments applied to every Kelvin
+// temperature a provider reports, before readings are cached or averaged.
+// Providers without an entry are left unchanged.
+var transforms = map[string]func(float64) float64{}
+
+// transform applies the provider's transform, if it has one, to o.
+func transform(provider string, o observation) observation {
+	t, ok := transforms[provider]
+	if !ok {
+		return o
+	}
+
+	o.kelvin = t(o.kelvin)
+	if o.minKelvin != nil {
+		k := t(*o.minKelvin)
+		o.minKelvin = &k
+	}
+	if o.maxKelvin != nil {
+		k := t(*o.maxKelvin)
+		o.maxKelvin = &k
+	}
+	return o
+}
+
+// offsetProviders installs a calibration offset transform for each provider
+// in spec, a comma-separated list of name=kelvin pairs such as
+// "openweathermap=1,weatherunderground=-0.5".
+func offsetProviders(spec string) error {
+	pairs, err := parseProviderPairs(spec)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range pairs {
+		offset, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid offset for %s: %v", name, err)
+		}
+		transforms[name] = func(k float64) float64 { return k + offset }
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestOffsetProviders(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]float64 // offset applied to each provider
+		wantErr bool
+	}{
+		{"", map[string]float64{"openweathermap": 0, "weatherunderground": 0}, false},
+		{"openweathermap=1", map[string]float64{"openweathermap": 1, "weatherunderground": 0}, false},
+		{"openweathermap=1,weatherunderground=-0.5", map[string]float64{"openweathermap": 1, "weatherunderground": -0.5}, false},
+		{"openweathermap=warm", nil, true},
+		{"nws=1", nil, true},
+	}
+
+	for _, tt := range tests {
+		transforms = map[string]func(float64) float64{}
+		err := offsetProviders(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("offsetProviders(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		for name, offset := range tt.want {
+			min := 280.0
+			o := transform(name, observation{kelvin: 290, minKelvin: &min})
+			if o.kelvin != 290+offset || *o.minKelvin != 280+offset {
+				t.Errorf("%q: %s read %vK with a %vK low, want offsets of %v", tt.spec, name, o.kelvin, *o.minKelvin, offset)
+			}
+		}
+	}
+	transforms = map[string]func(float64) float64{}
+}
+
+func TestWeatherAppliesOffset(t *testing.T) {
+	defer func() { transforms = map[string]func(float64) float64{} }()
+	if err := offsetProviders("openweathermap=1"); err != nil {
+		t.Fatal(err)
+	}
+	useGroups(t, multiWeatherProvider{reading("openweathermap", 290), reading("weatherunderground", 290)})
+
+	_, body := getWeather(t, "/weather/Paris?units=k", nil)
+	if body["temp_k"] != 290.5 {
+		t.Errorf("temp_k = %v, want 290.5 with openweathermap 1K warmer", body["temp_k"])
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Temperature units a response can be given in.
+const (
+	unitsKelvin     = "kelvin"
+	unitsCelsius    = "celsius"
+	unitsFahrenheit = "fahrenheit"
+)
+
+// defaultUnits is used when a request neither asks for units nor sends an
+// Accept-Language with a region.
+var defaultUnits = unitsFahrenheit
+
+// fahrenheitRegions are the regions, as they appear in Accept-Language,
+// that use Fahrenheit. Every other region uses Celsius.
+var fahrenheitRegions = map[string]bool{
+	"US": true, // United States
+	"BS": true, // Bahamas
+	"BZ": true, // Belize
+	"KY": true, // Cayman Islands
+	"LR": true, // Liberia
+	"PW": true, // Palau
+	"FM": true, // Micronesia
+	"MH": true, // Marshall Islands
+}
+
+// parseUnits accepts a unit's name or its initial.
+func parseUnits(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "k", unitsKelvin:
+		return unitsKelvin, nil
+	case "c", unitsCelsius:
+		return unitsCelsius, nil
+	case "f", unitsFahrenheit:
+		return unitsFahrenheit, nil
+	}
+	return "", fmt.Errorf("unknown units %q", s)
+}
+
+// requestUnits returns the units a request wants temperatures in. An
+// explicit ?units= always wins. Otherwise the region of the first language
+// in Accept-Language decides: Fahrenheit for the regions listed in
+// fahrenheitRegions and Celsius for any other. Without either,
+// defaultUnits is used.
+func requestUnits(req *http.Request) (string, error) {
+	if u := req.URL.Query().Get("units"); u != "" {
+		return parseUnits(u)
+	}
+
+	lang := strings.SplitN(req.Header.Get("Accept-Language"), ",", 2)[0]
+	lang = strings.TrimSpace(strings.SplitN(lang, ";", 2)[0])
+	if parts := strings.Split(lang, "-"); len(parts) > 1 {
+		region := strings.ToUpper(parts[len(parts)-1])
+		if len(region) == 2 {
+			if fahrenheitRegions[region] {
+				return unitsFahrenheit, nil
+			}
+			return unitsCelsius, nil
+		}
+	}
+
+	return defaultUnits, nil
+}
+
+// conversionPrecision is the number of decimal places temperatures are
+// rounded to when converted for display. It is far finer than any provider
+// reports, but coarse enough to remove floating point noise, so that 20°C
+// converted to Kelvin and back is exactly 20°C again, and -40°C is shown as
+// 233.15K. Kelvin values are kept unrounded internally so the noise does not
+// compound.
+const conversionPrecision = 9
+
+// roundConversion rounds a converted temperature to conversionPrecision
+// decimal places.
+func roundConversion(v float64) float64 {
+	scale := math.Pow(10, conversionPrecision)
+	return math.Round(v*scale) / scale
+}
+
+// celsiusToKelvin converts a Celsius temperature to Kelvin.
+func celsiusToKelvin(c float64) float64 {
+	return c + 273.15
+}
+
+// fahrenheitToKelvin converts a Fahrenheit temperature to Kelvin.
+func fahrenheitToKelvin(f float64) float64 {
+	return (f + 459.67) / 1.8
+}
+
+// fromKelvin converts a Kelvin temperature to units.
+func fromKelvin(k float64, units string) float64 {
+	switch units {
+	case unitsCelsius:
+		return roundConversion(k - 273.15)
+	case unitsFahrenheit:
+		return roundConversion((k * 1.8) - 459.67)
+	}
+	return roundConversion(k)
+}
+
+// requestSigFigs returns the number of significant figures asked for with
+// ?sigfigs=, or 0 when temperatures should not be rounded.
+func requestSigFigs(req *http.Request) (int, error) {
+	v := req.URL.Query().Get("sigfigs")
+	if v == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > 6 {
+		return 0, fmt.Errorf("sigfigs must be between 1 and 6")
+	}
+	return n, nil
+}
+
+// roundToSigFigs rounds v to n significant figures. An n of 0 leaves v
+// unchanged.
+func roundToSigFigs(v float64, n int) float64 {
+	if n <= 0 || v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+
+	magnitude := math.Ceil(math.Log10(math.Abs(v)))
+	scale := math.Pow(10, float64(n)-magnitude)
+	return math.Round(v*scale) / scale
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestUnits(t *testing.T) {
+	tests := []struct {
+		query, acceptLanguage string
+		want                  string
+		wantErr               bool
+	}{
+		{"", "en-US", unitsFahrenheit, false},
+		{"", "en-GB", unitsCelsius, false},
+		{"", "en-gb,en;q=0.8", unitsCelsius, false},
+		{"", "es-BZ;q=0.9", unitsFahrenheit, false},
+		{"", "zh-Hant-TW", unitsCelsius, false},
+		{"", "fr", defaultUnits, false},
+		{"", "", defaultUnits, false},
+		{"", "es-419", defaultUnits, false},
+		{"units=c", "en-US", unitsCelsius, false},
+		{"units=Kelvin", "en-GB", unitsKelvin, false},
+		{"units=rankine", "en-US", "", true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/weather/London?"+tt.query, nil)
+		if tt.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		got, err := requestUnits(req)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("?%s with Accept-Language %q = %q, %v, want %q", tt.query, tt.acceptLanguage, got, err, tt.want)
+		}
+	}
+}
+
+func TestRoundToSigFigs(t *testing.T) {
+	tests := []struct {
+		v    float64
+		n    int
+		want float64
+	}{
+		{293.456, 3, 293},
+		{293.456, 4, 293.5},
+		{293.456, 6, 293.456},
+		{293.456, 1, 300},
+		{20.55, 2, 21},
+		{-40.123, 3, -40.1},
+		{0.0123456, 2, 0.012},
+		{0.000987, 1, 0.001},
+		{1234567, 3, 1230000},
+		{99.96, 3, 100},
+		{0, 3, 0},
+		{293.456, 0, 293.456},
+	}
+
+	for _, tt := range tests {
+		if got := roundToSigFigs(tt.v, tt.n); got != tt.want {
+			t.Errorf("roundToSigFigs(%v, %d) = %v, want %v", tt.v, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRequestSigFigs(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"sigfigs=1", 1, false},
+		{"sigfigs=6", 6, false},
+		{"sigfigs=0", 0, true},
+		{"sigfigs=7", 0, true},
+		{"sigfigs=three", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := requestSigFigs(httptest.NewRequest(http.MethodGet, "/weather/London?"+tt.query, nil))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("?%s = %d, %v, want %d", tt.query, got, err, tt.want)
+		}
+	}
+}
+
+func TestWeatherSigFigsOnlyRoundsDisplay(t *testing.T) {
+	useGroups(t, multiWeatherProvider{reading("fake", 293.456)})
+
+	_, body := getWeather(t, "/weather/London?units=k&sigfigs=3", nil)
+	if body["temp"] != 293.0 || body["temp_k"] != 293.456 {
+		t.Errorf("temp %v and temp_k %v, want 293 and 293.456", body["temp"], body["temp_k"])
+	}
+}
+
+func TestConversionRoundTrip(t *testing.T) {
+	for c := -100; c <= 100; c++ {
+		if got := fromKelvin(celsiusToKelvin(float64(c)), unitsCelsius); got != float64(c) {
+			t.Errorf("%d°C round-tripped to %v°C", c, got)
+		}
+		if got := fromKelvin(celsiusToKelvin(float64(c)+0.1), unitsCelsius); got != float64(c)+0.1 {
+			t.Errorf("%v°C round-tripped to %v°C", float64(c)+0.1, got)
+		}
+	}
+	for f := -150; f <= 212; f++ {
+		if got := fromKelvin(fahrenheitToKelvin(float64(f)), unitsFahrenheit); got != float64(f) {
+			t.Errorf("%d°F round-tripped to %v°F", f, got)
+		}
+	}
+
+	tests := []struct {
+		celsius float64
+		units   string
+		want    float64
+	}{
+		{-40, unitsFahrenheit, -40},
+		{-40, unitsKelvin, 233.15},
+		{20, unitsKelvin, 293.15},
+		{100, unitsFahrenheit, 212},
+		{36.6, unitsFahrenheit, 97.88},
+	}
+
+	for _, tt := range tests {
+		if got := fromKelvin(celsiusToKelvin(tt.celsius), tt.units); got != tt.want {
+			t.Errorf("%v°C in %s = %v, want %v", tt.celsius, tt.units, got, tt.want)
+		}
+	}
+}